@@ -2,10 +2,10 @@ package cache
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/cfwidget/updatejson/env"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,24 +15,16 @@ type CachedResponse struct {
 	Status   int
 }
 
-var cacheTtl time.Duration
+var cacheTtl = time.Hour
 var memcache sync.Map
 
-func init() {
-	envCache := env.Get("CACHE_TTL")
-	cacheTtl = time.Hour
-	if envCache != "" {
-		var err error
-		cacheTtl, err = time.ParseDuration(envCache)
-		if err != nil {
-			panic(err)
-		}
-	}
+// Initialize sets how long responses are kept, and starts removing expired ones in the background
+func Initialize(ttl time.Duration) {
+	cacheTtl = ttl
 
 	go func() {
 		c := time.NewTicker(5 * time.Minute)
-		select {
-		case <-c.C:
+		for range c.C {
 			cleanCache()
 		}
 	}()
@@ -76,6 +68,16 @@ func Remove(key string) {
 	memcache.Delete(key)
 }
 
+// RemoveWithQuery removes the response for the key, along with those for the key with any query
+func RemoveWithQuery(key string) {
+	memcache.Range(func(k, v any) bool {
+		if s, ok := k.(string); ok && (s == key || strings.HasPrefix(s, key+"?")) {
+			memcache.Delete(k)
+		}
+		return true
+	})
+}
+
 func GetKey(c *gin.Context) string {
 	return c.Request.Host + c.Request.RequestURI
 }
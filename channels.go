@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cfwidget/updatejson/config"
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/models"
+)
+
+var ErrInvalidChannel = fmt.Errorf("invalid channel, must be one of %s", strings.Join(config.ReleaseTypes, ", "))
+
+// channels which get their own promo keys when asked for, on top of -latest and -recommended
+var extraChannels = []int8{releaseTypeBeta, releaseTypeAlpha}
+
+// parseChannel converts a requested channel to the least stable release type it allows, where no channel allows all
+func parseChannel(name string) (int8, error) {
+	if name == "" {
+		return 0, nil
+	}
+	if i := slices.Index(config.ReleaseTypes, strings.ToLower(name)); i >= 0 {
+		return int8(i + 1), nil
+	}
+	return 0, ErrInvalidChannel
+}
+
+// getChannelKeys lists the extra channels to add keys for when the request is limited to the channel. Less stable
+// channels have no files left to pick from, so their keys would only repeat -latest.
+func getChannelKeys(channel int8) []int8 {
+	keys := make([]int8, 0, len(extraChannels))
+	for _, v := range extraChannels {
+		if channel == 0 || v <= channel {
+			keys = append(keys, v)
+		}
+	}
+	return keys
+}
+
+// getReleaseTypeName returns the name of the CurseForge release type, such as beta
+func getReleaseTypeName(releaseType int8) string {
+	if releaseType < 1 || int(releaseType) > len(config.ReleaseTypes) {
+		return "unknown"
+	}
+	return config.ReleaseTypes[releaseType-1]
+}
+
+// inChannel checks if a file's release type is as stable as the channel requires. Files of an unknown type are only
+// used when no channel is requested.
+func inChannel(version *models.Version, channel int8) bool {
+	return channel == 0 || (version.Type > 0 && version.Type <= channel)
+}
+
+// isBadRequest checks if the error was caused by what the client asked for rather than by us
+func isBadRequest(err error) bool {
+	return errors.Is(err, curseforge.ErrInvalidProjectId) || errors.Is(err, curseforge.ErrUnsupportedGame) ||
+		errors.Is(err, ErrInvalidChannel) || errors.Is(err, ErrInvalidFormat) || errors.Is(err, ErrInvalidTime) ||
+		errors.Is(err, ErrInvalidPage) || errors.Is(err, ErrMissingVersion)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cfwidget/updatejson/cache"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/cfwidget/updatejson/util"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+// statuses of Forge's update checker
+const (
+	statusUpToDate     = "UP_TO_DATE"
+	statusOutdated     = "OUTDATED"
+	statusAhead        = "AHEAD"
+	statusBeta         = "BETA"
+	statusBetaOutdated = "BETA_OUTDATED"
+	statusFailed       = "FAILED"
+)
+
+var ErrMissingVersion = errors.New("current and mc must be given")
+
+// checkVersion tells the client if there is an update for the version it runs, the same way Forge's update checker does
+func checkVersion(c *gin.Context) {
+	pid := c.Param("projectId")
+	modId := c.Param("modId")
+	loader := getLoader(c)
+	current := c.Query("current")
+	gameVersion := c.Query("mc")
+
+	cacheKey := cache.GetKey(c)
+
+	var projectId uint
+	var err error
+	if projectId, err = cast.ToUintE(pid); err != nil {
+		cache.Set(cacheKey, http.StatusNotFound, nil)
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var data *models.UpdateJson
+	if current == "" || gameVersion == "" {
+		err = ErrMissingVersion
+	} else {
+		data, err = getUpdateJson(projectId, modId, loader, getUpdateOptions(c), c.Request.Context())
+	}
+
+	if isBadRequest(err) {
+		d := map[string]string{"error": err.Error()}
+		cache.Set(cacheKey, http.StatusBadRequest, d)
+		c.JSON(http.StatusBadRequest, d)
+	} else if err != nil {
+		logger.Printf(c.Request.Context(), "Error: %s", err.Error())
+		d := map[string]string{"status": statusFailed, "error": err.Error()}
+		cacheExpireTime := cache.Set(cacheKey, http.StatusInternalServerError, d)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.JSON(http.StatusInternalServerError, d)
+	} else {
+		result := getVersionCheck(data, current, gameVersion)
+		cacheExpireTime := cache.Set(cacheKey, http.StatusOK, result)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// getVersionCheck compares the version against the promos for the Minecraft version, following Forge's VersionChecker
+func getVersionCheck(data *models.UpdateJson, current string, gameVersion string) models.VersionCheck {
+	result := models.VersionCheck{Status: statusBeta, HomePage: data.HomePage}
+
+	recommended, hasRecommended := data.Promos[gameVersion+"-recommended"]
+	latest, hasLatest := data.Promos[gameVersion+"-latest"]
+
+	if hasRecommended {
+		diff := util.CompareVersions(recommended, current)
+		if diff == 0 {
+			result.Status = statusUpToDate
+		} else if diff < 0 {
+			result.Status = statusAhead
+			if hasLatest && util.CompareVersions(current, latest) < 0 {
+				result.Status = statusOutdated
+				result.Target = latest
+			}
+		} else {
+			result.Status = statusOutdated
+			result.Target = recommended
+		}
+	} else if hasLatest {
+		if util.CompareVersions(current, latest) < 0 {
+			result.Status = statusBetaOutdated
+		}
+		result.Target = latest
+	}
+
+	return result
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"github.com/cfwidget/updatejson/models"
+)
+
+var errInvalidClassFile = errors.New("invalid class file")
+
+// descriptors of the @Mod annotation used by Forge before mcmod.info was required (cpw) and after the package move (net)
+var modAnnotations = []string{
+	"Lcpw/mods/fml/common/Mod;",
+	"Lnet/minecraftforge/fml/common/Mod;",
+}
+
+// scanModAnnotations looks through the class files of a jar for @Mod annotations. This is slow compared to reading a
+// metadata file, so only use it when a jar has none.
+func scanModAnnotations(file *zip.Reader) (*models.ModInfo, []models.EntryError, error) {
+	var result *models.ModInfo
+	entryErrors := make([]models.EntryError, 0)
+	for _, f := range file.File {
+		if !strings.HasSuffix(f.Name, ".class") {
+			continue
+		}
+
+		data, err := readZipEntry(f)
+		if errors.Is(err, ErrArchiveLimit) {
+			return nil, entryErrors, err
+		} else if err != nil {
+			entryErrors = append(entryErrors, models.EntryError{Entry: f.Name, Error: err.Error()})
+			continue
+		}
+
+		//skip parsing anything that can't have the annotation
+		if !bytes.Contains(data, []byte("fml/common/Mod;")) {
+			continue
+		}
+
+		mod, err := readModAnnotation(data)
+		if err != nil {
+			entryErrors = append(entryErrors, models.EntryError{Entry: f.Name, Error: err.Error()})
+			continue
+		}
+		if mod == nil || mod.ModId == "" {
+			continue
+		}
+
+		if result == nil {
+			result = &models.ModInfo{ModLoader: "forge"}
+		}
+		result.Mods = append(result.Mods, *mod)
+	}
+
+	return result, entryErrors, nil
+}
+
+type classReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (r *classReader) u1() uint8 {
+	if r.err != nil || r.offset+1 > len(r.data) {
+		r.err = errInvalidClassFile
+		return 0
+	}
+	v := r.data[r.offset]
+	r.offset++
+	return v
+}
+
+func (r *classReader) u2() uint16 {
+	if r.err != nil || r.offset+2 > len(r.data) {
+		r.err = errInvalidClassFile
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.data[r.offset:])
+	r.offset += 2
+	return v
+}
+
+func (r *classReader) u4() uint32 {
+	if r.err != nil || r.offset+4 > len(r.data) {
+		r.err = errInvalidClassFile
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data[r.offset:])
+	r.offset += 4
+	return v
+}
+
+func (r *classReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.offset+n > len(r.data) {
+		r.err = errInvalidClassFile
+		return nil
+	}
+	v := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return v
+}
+
+func (r *classReader) skip(n int) {
+	r.bytes(n)
+}
+
+// readModAnnotation reads the class file far enough to find the annotations on the class itself. Only the UTF8
+// entries of the constant pool are kept, since the annotation values we care about can only point to those.
+func readModAnnotation(data []byte) (*models.Mod, error) {
+	r := &classReader{data: data}
+
+	if r.u4() != 0xCAFEBABE {
+		return nil, errInvalidClassFile
+	}
+	r.skip(4) //minor and major version
+
+	count := int(r.u2())
+	pool := make(map[uint16]string)
+	for i := 1; i < count && r.err == nil; i++ {
+		switch tag := r.u1(); tag {
+		case 1: //Utf8
+			length := int(r.u2())
+			pool[uint16(i)] = string(r.bytes(length))
+		case 3, 4: //Integer, Float
+			r.skip(4)
+		case 5, 6: //Long, Double take up two entries
+			r.skip(8)
+			i++
+		case 7, 8, 16, 19, 20: //Class, String, MethodType, Module, Package
+			r.skip(2)
+		case 9, 10, 11, 12, 17, 18: //Fieldref, Methodref, InterfaceMethodref, NameAndType, Dynamic, InvokeDynamic
+			r.skip(4)
+		case 15: //MethodHandle
+			r.skip(3)
+		default:
+			return nil, errInvalidClassFile
+		}
+	}
+
+	r.skip(6) //access flags, this class, super class
+	r.skip(int(r.u2()) * 2)
+
+	//fields and methods have the same layout, and we don't need either
+	for range 2 {
+		members := int(r.u2())
+		for i := 0; i < members && r.err == nil; i++ {
+			r.skip(6)
+			skipAttributes(r)
+		}
+	}
+
+	attributes := int(r.u2())
+	for i := 0; i < attributes && r.err == nil; i++ {
+		name := pool[r.u2()]
+		length := int(r.u4())
+		body := r.bytes(length)
+		if name != "RuntimeVisibleAnnotations" || r.err != nil {
+			continue
+		}
+
+		mod, err := readAnnotations(&classReader{data: body}, pool)
+		if err != nil || mod != nil {
+			return mod, err
+		}
+	}
+
+	return nil, r.err
+}
+
+func skipAttributes(r *classReader) {
+	attributes := int(r.u2())
+	for i := 0; i < attributes && r.err == nil; i++ {
+		r.skip(2)
+		r.skip(int(r.u4()))
+	}
+}
+
+func readAnnotations(r *classReader, pool map[uint16]string) (*models.Mod, error) {
+	annotations := int(r.u2())
+	for i := 0; i < annotations && r.err == nil; i++ {
+		descriptor := pool[r.u2()]
+		isMod := false
+		for _, v := range modAnnotations {
+			if descriptor == v {
+				isMod = true
+			}
+		}
+
+		mod := &models.Mod{Side: "BOTH"}
+		pairs := int(r.u2())
+		for z := 0; z < pairs && r.err == nil; z++ {
+			name := pool[r.u2()]
+			value := readElementValue(r, pool)
+			if !isMod {
+				continue
+			}
+
+			switch name {
+			case "modid":
+				mod.ModId = value
+			case "version":
+				mod.Version = value
+			case "name":
+				mod.DisplayName = value
+			}
+		}
+
+		if isMod && r.err == nil {
+			return mod, nil
+		}
+	}
+
+	return nil, r.err
+}
+
+// readElementValue skips over an annotation value, returning it if it's a string constant
+func readElementValue(r *classReader, pool map[uint16]string) string {
+	switch tag := r.u1(); tag {
+	case 's':
+		return pool[r.u2()]
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 'c':
+		r.skip(2)
+	case 'e':
+		r.skip(4)
+	case '@':
+		r.skip(2)
+		pairs := int(r.u2())
+		for i := 0; i < pairs && r.err == nil; i++ {
+			r.skip(2)
+			readElementValue(r, pool)
+		}
+	case '[':
+		values := int(r.u2())
+		for i := 0; i < values && r.err == nil; i++ {
+			readElementValue(r, pool)
+		}
+	default:
+		r.err = errInvalidClassFile
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ScanModAnnotations(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"com/example/ExampleMod.class":   string(buildModClass(t, "Lcpw/mods/fml/common/Mod;")),
+		"com/example/Other.class":        string(buildModClass(t, "Lcom/example/NotMod;")),
+		"assets/example/lang/en_US.lang": "",
+	})
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !assert.NoError(t, err, "error reading file") {
+		return
+	}
+
+	modInfo, err := parseJarFile(r, context.Background())
+	if !assert.NoError(t, err, "error parsing file") {
+		return
+	}
+	if !assert.NotNil(t, modInfo, "error parsing file") || !assert.Len(t, modInfo.Mods, 1) {
+		return
+	}
+
+	assert.Equal(t, "forge", modInfo.ModLoader)
+	assert.Equal(t, "examplemod", modInfo.Mods[0].ModId)
+	assert.Equal(t, "1.2.3", modInfo.Mods[0].Version)
+}
+
+func Test_ReadModAnnotationInvalid(t *testing.T) {
+	valid := buildModClass(t, "Lnet/minecraftforge/fml/common/Mod;")
+
+	mod, err := readModAnnotation(valid)
+	if assert.NoError(t, err) && assert.NotNil(t, mod) {
+		assert.Equal(t, "examplemod", mod.ModId)
+	}
+
+	_, err = readModAnnotation(valid[:len(valid)-5])
+	assert.ErrorIs(t, err, errInvalidClassFile)
+
+	_, err = readModAnnotation([]byte("not a class"))
+	assert.ErrorIs(t, err, errInvalidClassFile)
+}
+
+// buildModClass writes a minimal class file with a single method and the given annotation on the class, setting
+// modid and version
+func buildModClass(t *testing.T, annotation string) []byte {
+	var buf bytes.Buffer
+	write := func(v any) {
+		if err := binary.Write(&buf, binary.BigEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	utf8 := func(v string) {
+		write(uint8(1))
+		write(uint16(len(v)))
+		buf.WriteString(v)
+	}
+
+	write(uint32(0xCAFEBABE))
+	write(uint16(0))
+	write(uint16(50))
+
+	write(uint16(11))
+	utf8(annotation)                  //1
+	utf8("modid")                     //2
+	utf8("examplemod")                //3
+	utf8("version")                   //4
+	utf8("1.2.3")                     //5
+	utf8("RuntimeVisibleAnnotations") //6
+	utf8("com/example/ExampleMod")    //7
+	write(uint8(7))                   //8
+	write(uint16(7))
+	write(uint8(5)) //9 and 10
+	write(int64(42))
+
+	write(uint16(0x21)) //access flags
+	write(uint16(8))    //this
+	write(uint16(8))    //super
+	write(uint16(0))    //interfaces
+	write(uint16(0))    //fields
+
+	write(uint16(1)) //methods
+	write(uint16(1))
+	write(uint16(2))
+	write(uint16(2))
+	write(uint16(1))
+	write(uint16(6))
+	write(uint32(2))
+	write(uint16(0))
+
+	var annotations bytes.Buffer
+	for _, v := range []any{uint16(1), uint16(1), uint16(2), uint16(2), uint8('s'), uint16(3), uint16(4), uint8('s'), uint16(5)} {
+		if err := binary.Write(&annotations, binary.BigEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(uint16(1)) //attributes
+	write(uint16(6))
+	write(uint32(annotations.Len()))
+	buf.Write(annotations.Bytes())
+
+	return buf.Bytes()
+}
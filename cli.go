@@ -0,0 +1,388 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/cfwidget/updatejson/cache"
+	"github.com/cfwidget/updatejson/config"
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/database"
+	"github.com/cfwidget/updatejson/env"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/cfwidget/updatejson/store"
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+)
+
+var errUsage = errors.New("usage")
+
+const usage = `Usage: updatejson [--config file] [--print-config] [command]
+
+Commands:
+  serve                               run the web server (default)
+  index <project>                     download and parse every file of a project
+  reparse [--project id] [--file id]  parse files again with the current parser, all outdated files if neither is given
+  store purge                         remove every file from the jar store
+  db migrate                          create and update the database tables
+  db stats                            print counts of what is stored
+  inspect <jar>                       print what is read from a jar file
+`
+
+// applyConfig hands the settings to everything that needs them
+func applyConfig(cfg *config.Config) {
+	host = cfg.Host
+	expandRangeProjects = cfg.ExpandRanges
+	promotion = cfg.Promotion
+
+	cache.Initialize(time.Duration(cfg.Cache.TTL))
+	curseforge.Initialize(cfg.CoreKey, cfg.Debug)
+	store.Initialize(cfg.Store.Dir, cfg.Store.Size, store.Mode(cfg.Store.Mode))
+
+	limits = newArchiveLimits(cfg.Limits)
+	failureBackoff = time.Duration(cfg.Failures.Backoff)
+	failureMaxBackoff = time.Duration(cfg.Failures.MaxBackoff)
+	lazyReparses = &throttle{limit: cfg.Reparse.PerMinute, interval: time.Minute}
+	packVersionRegex = regexp.MustCompile(cfg.Packs.VersionPattern)
+	packVersionSource = cfg.Packs.VersionSource
+}
+
+// openDatabase checks the database settings and connects, for the commands which use it
+func openDatabase(cfg *config.Config) error {
+	if err := cfg.Database.Validate(); err != nil {
+		return err
+	}
+	database.Initialize(cfg.Database)
+	return nil
+}
+
+// runCommand runs the subcommand named by the args, returning the exit code
+func runCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("updatejson", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configFile := flags.String("config", env.Get("CONFIG_FILE"), "config file to load")
+	printConfig := flags.Bool("print-config", false, "print the config and exit")
+	if err := flags.Parse(args); err != nil {
+		_, _ = fmt.Fprint(stderr, usage)
+		return 2
+	}
+	args = flags.Args()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Invalid configuration:\n%s\n", err.Error())
+		return 1
+	}
+
+	if *printConfig {
+		printed, err := cfg.Print()
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+			return 1
+		}
+		_, _ = fmt.Fprint(stdout, printed)
+		return 0
+	}
+
+	applyConfig(cfg)
+
+	//the container passes an empty argument when no command is given
+	if len(args) == 0 || args[0] == "" || args[0] == "serve" {
+		if err = cfg.Database.Validate(); err != nil {
+			_, _ = fmt.Fprintf(stderr, "Invalid configuration:\n%s\n", err.Error())
+			return 1
+		}
+		startWorkers(cfg.Downloaders)
+		serve(cfg)
+		return 0
+	}
+
+	ctx := context.WithValue(context.Background(), logger.ContextKey, logger.New("CLI"))
+
+	switch args[0] {
+	case "index":
+		err = indexCommand(args[1:], cfg, stdout, ctx)
+	case "reparse":
+		err = reparseCommand(args[1:], cfg, stdout, ctx)
+	case "store":
+		err = storeCommand(args[1:], stdout)
+	case "db":
+		err = dbCommand(args[1:], cfg, stdout, ctx)
+	case "inspect":
+		err = inspectCommand(args[1:], stdout, ctx)
+	case "help", "-h", "--help":
+		_, _ = fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		err = errUsage
+	}
+
+	if errors.Is(err, errUsage) {
+		_, _ = fmt.Fprint(stderr, usage)
+		return 2
+	} else if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+func indexCommand(args []string, cfg *config.Config, stdout io.Writer, ctx context.Context) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	projectId, err := cast.ToUintE(args[0])
+	if err != nil {
+		return errUsage
+	}
+
+	if err = openDatabase(cfg); err != nil {
+		return err
+	}
+	startWorkers(cfg.Downloaders)
+
+	//what we have stored is no use here, the point is to read the files from CurseForge
+	_, versionMap, fileErrors, err := fetchProjectVersions(projectId, ctx)
+	if errors.Is(err, curseforge.ErrUnauthorized) {
+		return fmt.Errorf("CurseForge would not list the files of project %d, check CORE_KEY: %w", projectId, err)
+	} else if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "Indexed %d mods of project %d\n", len(versionMap), projectId)
+	for fileId, v := range fileErrors {
+		_, _ = fmt.Fprintf(stdout, "File %d failed: %s\n", fileId, v.Error())
+	}
+	return nil
+}
+
+func reparseCommand(args []string, cfg *config.Config, stdout io.Writer, ctx context.Context) error {
+	flags := flag.NewFlagSet("reparse", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	projectId := flags.Uint("project", 0, "project to reparse")
+	fileId := flags.Uint("file", 0, "file to reparse")
+	limit := flags.Int("limit", 100, "most outdated files to reparse")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return errUsage
+	}
+
+	if err := openDatabase(cfg); err != nil {
+		return err
+	}
+	startWorkers(cfg.Downloaders)
+	db, err := database.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	var files []outdatedFile
+	if *projectId == 0 && *fileId == 0 {
+		files, err = findOutdatedFiles(db, *limit)
+	} else {
+		query := db.Model(&models.Version{}).Select("curse_id, file_id").Group("curse_id, file_id").Order("file_id DESC")
+		if *projectId != 0 {
+			query = query.Where("curse_id = ?", *projectId)
+		}
+		if *fileId != 0 {
+			query = query.Where("file_id = ?", *fileId)
+		}
+		err = query.Scan(&files).Error
+		if err == nil {
+			//files that failed have no rows, but are asked for all the same, such as after raising the limits
+			files, err = addFailedFiles(db, files, *projectId, *fileId)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, v := range files {
+		if err = reparseStoredFile(db, v, ctx); err != nil {
+			failed++
+			_, _ = fmt.Fprintf(stdout, "File %d failed: %s\n", v.FileId, err.Error())
+		}
+	}
+
+	_, _ = fmt.Fprintf(stdout, "Reparsed %d of %d files\n", len(files)-failed, len(files))
+	return nil
+}
+
+// addFailedFiles adds the recorded failures of the project or file to the files to reparse
+func addFailedFiles(db *gorm.DB, files []outdatedFile, projectId uint, fileId uint) ([]outdatedFile, error) {
+	query := db.Model(&models.FileFailure{}).Select("curse_id, file_id").Order("file_id DESC")
+	if projectId != 0 {
+		query = query.Where("curse_id = ?", projectId)
+	}
+	if fileId != 0 {
+		query = query.Where("file_id = ?", fileId)
+	}
+
+	var failed []outdatedFile
+	if err := query.Scan(&failed).Error; err != nil {
+		return files, err
+	}
+	for _, v := range failed {
+		if !slices.ContainsFunc(files, func(f outdatedFile) bool { return f.FileId == v.FileId }) {
+			files = append(files, v)
+		}
+	}
+	return files, nil
+}
+
+// reparseStoredFile parses a file again, whether or not it is outdated, and even if it failed before. The failure is
+// cleared if it now works.
+func reparseStoredFile(db *gorm.DB, file outdatedFile, ctx context.Context) error {
+	project, err := curseforge.GetProject(file.CurseId, ctx)
+	if err != nil {
+		return err
+	}
+
+	curseFile, err := curseforge.GetFile(file.CurseId, file.FileId, ctx)
+	if err != nil {
+		return err
+	}
+
+	var old []*models.Version
+	err = db.Where(&models.Version{CurseId: file.CurseId, FileId: file.FileId}).Find(&old).Error
+	if err != nil {
+		return err
+	}
+
+	failure, err := getFileFailure(db, file.FileId)
+	if err != nil {
+		return err
+	}
+
+	_, err = reparseFile(db, project, curseFile, old, ctx)
+	if err != nil {
+		return recordFileFailure(db, project, curseFile, failure, err, ctx)
+	}
+	metricReparsed.Add(1)
+
+	if failure != nil {
+		return db.Delete(failure).Error
+	}
+	return nil
+}
+
+func storeCommand(args []string, stdout io.Writer) error {
+	if len(args) != 1 || args[0] != "purge" {
+		return errUsage
+	}
+	if !store.Enabled() {
+		return errors.New("jar store is not enabled, set JAR_STORE_DIR")
+	}
+
+	count, size, err := store.Stats()
+	if err != nil {
+		return err
+	}
+	if err = store.Purge(); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(stdout, "Removed %d files (%d bytes) from the jar store\n", count, size)
+	return nil
+}
+
+func dbCommand(args []string, cfg *config.Config, stdout io.Writer, ctx context.Context) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "migrate":
+		if err := openDatabase(cfg); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(stdout, "Database is up to date")
+		return nil
+	case "stats":
+		if err := openDatabase(cfg); err != nil {
+			return err
+		}
+		return printStats(stdout, ctx)
+	}
+	return errUsage
+}
+
+func printStats(stdout io.Writer, ctx context.Context) error {
+	db, err := database.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	stats := []struct {
+		Name  string
+		Query *gorm.DB
+	}{
+		{"Versions", db.Model(&models.Version{})},
+		{"Files", db.Model(&models.Version{}).Distinct("file_id")},
+		{"Projects", db.Model(&models.Version{}).Distinct("curse_id")},
+		{"Files without mods", db.Model(&models.Version{}).Where("mod_id = ?", "")},
+		{"Outdated versions", db.Model(&models.Version{}).Where("parser_version < ?", parserVersion)},
+		{"Dependencies", db.Model(&models.VersionDependency{})},
+		{"Failed files", db.Model(&models.FileFailure{})},
+		{"Permanently failed files", db.Model(&models.FileFailure{}).Where("permanent = ?", true)},
+		{"Promo snapshots", db.Model(&models.PromoSnapshot{})},
+	}
+
+	for _, v := range stats {
+		var count int64
+		if err = v.Query.Count(&count).Error; err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(stdout, "%-26s %d\n", v.Name+":", count)
+	}
+
+	if store.Enabled() {
+		count, size, err := store.Stats()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(stdout, "%-26s %d (%d bytes)\n", "Stored jars:", count, size)
+	}
+	return nil
+}
+
+func inspectCommand(args []string, stdout io.Writer, ctx context.Context) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	result, err := inspectUpload(r, ctx)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
@@ -0,0 +1,436 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cfwidget/updatejson/env"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/cast"
+)
+
+// Loaders are the mod loaders promos can be requested for
+var Loaders = []string{"forge", "fabric", "neoforge", "quilt"}
+
+// ReleaseTypes are the CurseForge release types, from most to least stable
+var ReleaseTypes = []string{"release", "beta", "alpha"}
+
+const redacted = "<redacted>"
+
+type Config struct {
+	Host         string    `toml:"host"`
+	Debug        bool      `toml:"debug"`
+	CoreKey      string    `toml:"core_key"`
+	Downloaders  int       `toml:"downloaders"`
+	ExpandRanges []uint    `toml:"expand_ranges"`
+	MetricsToken string    `toml:"metrics_token"`
+	Cache        Cache     `toml:"cache"`
+	Database     Database  `toml:"database"`
+	Limits       Limits    `toml:"limits"`
+	Failures     Failures  `toml:"failures"`
+	Reparse      Reparse   `toml:"reparse"`
+	Store        Store     `toml:"store"`
+	Packs        Packs     `toml:"packs"`
+	Promotion    Promotion `toml:"promotion"`
+	Preseed      []Preseed `toml:"preseed"`
+}
+
+type Cache struct {
+	TTL Duration `toml:"ttl"`
+}
+
+type Database struct {
+	Engine   string `toml:"engine"`
+	User     string `toml:"user"`
+	Pass     string `toml:"pass"`
+	Host     string `toml:"host"`
+	Database string `toml:"database"`
+	File     string `toml:"file"`
+	Mode     string `toml:"mode"`
+}
+
+type Limits struct {
+	DownloadSize     int64 `toml:"download_size"`
+	Entries          int   `toml:"entries"`
+	EntrySize        int64 `toml:"entry_size"`
+	CompressionRatio int64 `toml:"compression_ratio"`
+	NestingDepth     int   `toml:"nesting_depth"`
+}
+
+type Failures struct {
+	Backoff    Duration `toml:"backoff"`
+	MaxBackoff Duration `toml:"max_backoff"`
+}
+
+type Reparse struct {
+	PerMinute int      `toml:"per_minute"`
+	Interval  Duration `toml:"interval"`
+	Batch     int      `toml:"batch"`
+}
+
+type Store struct {
+	Dir  string `toml:"dir"`
+	Size int64  `toml:"size"`
+	Mode string `toml:"mode"`
+}
+
+type Packs struct {
+	VersionPattern string `toml:"version_pattern"`
+	VersionSource  string `toml:"version_source"`
+}
+
+// Promotion holds the policy used to pick promos, which projects can replace with their own
+type Promotion struct {
+	Default  PromotionPolicy            `toml:"default"`
+	Projects map[string]PromotionPolicy `toml:"projects"`
+}
+
+type PromotionPolicy struct {
+	// least stable release type used for -latest, one of release, beta or alpha, which is the default
+	Latest string `toml:"latest"`
+	// how long a release must be out before it is used for -recommended
+	RecommendedDelay Duration `toml:"recommended_delay"`
+	// use the newest beta for -recommended when a Minecraft version has no release
+	BetaFallback bool `toml:"beta_fallback"`
+	// promo keys which always point at the given version
+	Pinned map[string]string `toml:"pinned"`
+}
+
+// Get returns the policy of the project, or the default if it has none
+func (p Promotion) Get(projectId uint) PromotionPolicy {
+	if policy, exists := p.Projects[cast.ToString(projectId)]; exists {
+		return policy
+	}
+	return p.Default
+}
+
+// Preseed is a mod which is loaded into the cache before the server accepts requests
+type Preseed struct {
+	Project uint     `toml:"project"`
+	ModId   string   `toml:"mod_id"`
+	Loaders []string `toml:"loaders"`
+}
+
+// Duration is a time.Duration written the same way as in Go, such as "1h30m"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func Default() *Config {
+	return &Config{
+		Cache: Cache{TTL: Duration(time.Hour)},
+		Database: Database{
+			Engine: "sqlite3",
+		},
+		Limits: Limits{
+			DownloadSize:     256 * 1024 * 1024,
+			Entries:          100000,
+			EntrySize:        16 * 1024 * 1024,
+			CompressionRatio: 100,
+			NestingDepth:     1,
+		},
+		Failures: Failures{
+			Backoff:    Duration(5 * time.Minute),
+			MaxBackoff: Duration(24 * time.Hour),
+		},
+		Reparse: Reparse{
+			PerMinute: 10,
+			Batch:     10,
+		},
+		Store: Store{
+			Size: 1024 * 1024 * 1024,
+			Mode: "full",
+		},
+		Packs: Packs{
+			VersionPattern: `v?(\d+(?:\.\d+)+(?:[-+][0-9A-Za-z.\-]+)?)`,
+			VersionSource:  "name",
+		},
+		Promotion: Promotion{
+			Default: PromotionPolicy{Latest: "alpha"},
+		},
+	}
+}
+
+// Load reads the config file at the path, if one is given, then applies any environment variables on top. The result
+// is validated, so everything that is wrong is reported at once instead of failing later.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		err = toml.NewDecoder(f).DisallowUnknownFields().Decode(cfg)
+		var strictErr *toml.StrictMissingError
+		if errors.As(err, &strictErr) {
+			return nil, fmt.Errorf("%s: %s", path, strictErr.String())
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides the config with the environment variables used before there was a config file
+func (c *Config) applyEnv() error {
+	var errs []error
+	str := func(key string, target *string) {
+		if v := env.Get(key); v != "" {
+			*target = v
+		}
+	}
+	integer := func(key string, target *int) {
+		if v := env.Get(key); v != "" {
+			parsed, err := cast.ToIntE(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a number", key, v))
+				return
+			}
+			*target = parsed
+		}
+	}
+	integer64 := func(key string, target *int64) {
+		if v := env.Get(key); v != "" {
+			parsed, err := cast.ToInt64E(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a number", key, v))
+				return
+			}
+			*target = parsed
+		}
+	}
+	duration := func(key string, target *Duration) {
+		if v := env.Get(key); v != "" {
+			if err := target.UnmarshalText([]byte(v)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a duration, such as 30m or 1h", key, v))
+			}
+		}
+	}
+
+	str("HOST", &c.Host)
+	if v := env.Get("DEBUG"); v != "" {
+		c.Debug = cast.ToBool(v)
+	}
+	str("CORE_KEY", &c.CoreKey)
+	str("METRICS_TOKEN", &c.MetricsToken)
+	integer("DOWNLOADERS", &c.Downloaders)
+	if v := env.Get("EXPAND_RANGES"); v != "" {
+		c.ExpandRanges = make([]uint, 0)
+		for id := range strings.SplitSeq(v, ",") {
+			parsed, err := cast.ToUintE(strings.TrimSpace(id))
+			if err != nil || parsed == 0 {
+				errs = append(errs, fmt.Errorf("EXPAND_RANGES: %q is not a project id", id))
+				continue
+			}
+			c.ExpandRanges = append(c.ExpandRanges, parsed)
+		}
+	}
+
+	duration("CACHE_TTL", &c.Cache.TTL)
+
+	str("DB_ENGINE", &c.Database.Engine)
+	str("DB_USER", &c.Database.User)
+	str("DB_PASS", &c.Database.Pass)
+	str("DB_HOST", &c.Database.Host)
+	str("DB_DATABASE", &c.Database.Database)
+	str("DB_FILE", &c.Database.File)
+	str("DB_MODE", &c.Database.Mode)
+
+	integer64("MAX_DOWNLOAD_SIZE", &c.Limits.DownloadSize)
+	integer("MAX_ZIP_ENTRIES", &c.Limits.Entries)
+	integer64("MAX_ENTRY_SIZE", &c.Limits.EntrySize)
+	integer64("MAX_COMPRESSION_RATIO", &c.Limits.CompressionRatio)
+	integer("MAX_NESTING_DEPTH", &c.Limits.NestingDepth)
+
+	duration("FAILURE_BACKOFF", &c.Failures.Backoff)
+	duration("FAILURE_MAX_BACKOFF", &c.Failures.MaxBackoff)
+
+	integer("REPARSE_PER_MINUTE", &c.Reparse.PerMinute)
+	duration("REPARSE_INTERVAL", &c.Reparse.Interval)
+	integer("REPARSE_BATCH", &c.Reparse.Batch)
+
+	str("JAR_STORE_DIR", &c.Store.Dir)
+	integer64("JAR_STORE_SIZE", &c.Store.Size)
+	str("JAR_STORE_MODE", &c.Store.Mode)
+
+	str("PACK_VERSION_PATTERN", &c.Packs.VersionPattern)
+	str("PACK_VERSION_SOURCE", &c.Packs.VersionSource)
+
+	if v := env.Get("PRESEED"); v != "" {
+		preseed, err := parsePreseed(v)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		c.Preseed = preseed
+	}
+
+	return errors.Join(errs...)
+}
+
+// parsePreseed reads the PRESEED variable, a comma separated list of project:modid, optionally followed by the
+// loaders to seed separated with |, such as 238222:jei:forge|neoforge
+func parsePreseed(value string) ([]Preseed, error) {
+	var errs []error
+	result := make([]Preseed, 0)
+	for v := range strings.SplitSeq(value, ",") {
+		if v == "" {
+			continue
+		}
+
+		path := strings.Split(v, ":")
+		if len(path) < 2 || len(path) > 3 {
+			errs = append(errs, fmt.Errorf("PRESEED: %q must be project:modid or project:modid:loaders", v))
+			continue
+		}
+		project, err := cast.ToUintE(path[0])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("PRESEED: %q is not a project id", path[0]))
+			continue
+		}
+
+		preseed := Preseed{Project: project, ModId: path[1]}
+		if len(path) == 3 {
+			preseed.Loaders = strings.Split(path[2], "|")
+		}
+		result = append(result, preseed)
+	}
+	return result, errors.Join(errs...)
+}
+
+// Validate checks the database settings. These are only needed by the commands which use the database, so they are
+// not part of Config.Validate. An empty sqlite3 file is a temporary database, as it always has been.
+func (d Database) Validate() error {
+	switch d.Engine {
+	case "sqlite3":
+		return nil
+	case "mysql":
+		var errs []error
+		if d.Host == "" {
+			errs = append(errs, errors.New("database.host: required when engine is mysql"))
+		}
+		if d.Database == "" {
+			errs = append(errs, errors.New("database.database: required when engine is mysql"))
+		}
+		return errors.Join(errs...)
+	}
+	return fmt.Errorf("database.engine: must be one of sqlite3, mysql, got %q", d.Engine)
+}
+
+// Validate checks every setting, returning all problems found
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Downloaders >= 0, "downloaders: must not be negative")
+	check(c.Cache.TTL > 0, "cache.ttl: must be greater than 0")
+
+	check(c.Limits.DownloadSize > 0, "limits.download_size: must be greater than 0")
+	check(c.Limits.Entries > 0, "limits.entries: must be greater than 0")
+	check(c.Limits.EntrySize > 0, "limits.entry_size: must be greater than 0")
+	check(c.Limits.CompressionRatio > 0, "limits.compression_ratio: must be greater than 0")
+	check(c.Limits.NestingDepth >= 0, "limits.nesting_depth: must not be negative")
+
+	check(c.Failures.Backoff > 0, "failures.backoff: must be greater than 0")
+	check(c.Failures.MaxBackoff >= c.Failures.Backoff, "failures.max_backoff: must not be less than failures.backoff")
+
+	check(c.Reparse.PerMinute >= 0, "reparse.per_minute: must not be negative")
+	check(c.Reparse.Interval >= 0, "reparse.interval: must not be negative")
+	check(c.Reparse.Batch > 0, "reparse.batch: must be greater than 0")
+
+	check(c.Store.Size > 0, "store.size: must be greater than 0")
+	check(c.Store.Mode == "full" || c.Store.Mode == "metadata", "store.mode: must be one of full, metadata, got %q", c.Store.Mode)
+
+	if _, err := regexp.Compile(c.Packs.VersionPattern); err != nil {
+		errs = append(errs, fmt.Errorf("packs.version_pattern: %w", err))
+	}
+	check(c.Packs.VersionSource == "name" || c.Packs.VersionSource == "mcmeta", "packs.version_source: must be one of name, mcmeta, got %q", c.Packs.VersionSource)
+
+	errs = append(errs, c.Promotion.Default.validate("promotion.default")...)
+	for k, v := range c.Promotion.Projects {
+		_, err := cast.ToUintE(k)
+		check(err == nil, "promotion.projects.%s: must be a project id", k)
+		errs = append(errs, v.validate("promotion.projects."+k)...)
+	}
+
+	for k, v := range c.Preseed {
+		check(v.Project != 0, "preseed[%d].project: required", k)
+		check(v.ModId != "", "preseed[%d].mod_id: required", k)
+		for _, loader := range v.Loaders {
+			check(slices.Contains(Loaders, loader), "preseed[%d].loaders: %q is not one of %s", k, loader, strings.Join(Loaders, ", "))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p PromotionPolicy) validate(name string) []error {
+	var errs []error
+	if p.Latest != "" && !slices.Contains(ReleaseTypes, p.Latest) {
+		errs = append(errs, fmt.Errorf("%s.latest: must be one of %s, got %q", name, strings.Join(ReleaseTypes, ", "), p.Latest))
+	}
+	if p.RecommendedDelay < 0 {
+		errs = append(errs, fmt.Errorf("%s.recommended_delay: must not be negative", name))
+	}
+	for k := range p.Pinned {
+		if !strings.HasSuffix(k, "-latest") && !strings.HasSuffix(k, "-recommended") {
+			errs = append(errs, fmt.Errorf("%s.pinned: %q must end in -latest or -recommended", name, k))
+		}
+	}
+	return errs
+}
+
+// GetLoaders returns the loaders to seed, which is all of them unless some are listed
+func (p Preseed) GetLoaders() []string {
+	if len(p.Loaders) == 0 {
+		return Loaders
+	}
+	return p.Loaders
+}
+
+// Print writes the config as TOML, with secrets hidden
+func (c *Config) Print() (string, error) {
+	copied := *c
+	if copied.CoreKey != "" {
+		copied.CoreKey = redacted
+	}
+	if copied.MetricsToken != "" {
+		copied.MetricsToken = redacted
+	}
+	if copied.Database.Pass != "" {
+		copied.Database.Pass = redacted
+	}
+
+	data, err := toml.Marshal(copied)
+	return string(data), err
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `host = "curseupdate.com"
+core_key = "secret-key"
+metrics_token = "secret-token"
+
+[cache]
+ttl = "30m"
+
+[database]
+engine = "sqlite3"
+file = "/database/updatejson.db"
+
+[[preseed]]
+project = 238222
+mod_id = "jei"
+loaders = ["forge", "neoforge"]
+`
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "updatejson.toml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_Load(t *testing.T) {
+	t.Setenv("CACHE_TTL", "")
+	t.Setenv("DB_FILE", "")
+	t.Setenv("PRESEED", "")
+
+	cfg, err := Load(writeConfig(t, testConfig))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "curseupdate.com", cfg.Host)
+	assert.Equal(t, Duration(30*time.Minute), cfg.Cache.TTL)
+	assert.Equal(t, []Preseed{{Project: 238222, ModId: "jei", Loaders: []string{"forge", "neoforge"}}}, cfg.Preseed)
+	assert.Equal(t, int64(256*1024*1024), cfg.Limits.DownloadSize)
+
+	t.Setenv("CACHE_TTL", "5m")
+	t.Setenv("PRESEED", "1:examplemod,2:otherlib:fabric|quilt")
+	cfg, err = Load(writeConfig(t, testConfig))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, Duration(5*time.Minute), cfg.Cache.TTL)
+	assert.Equal(t, []Preseed{
+		{Project: 1, ModId: "examplemod"},
+		{Project: 2, ModId: "otherlib", Loaders: []string{"fabric", "quilt"}},
+	}, cfg.Preseed)
+	assert.Equal(t, Loaders, cfg.Preseed[0].GetLoaders())
+
+	printed, err := cfg.Print()
+	assert.NoError(t, err)
+	assert.NotContains(t, printed, "secret-key")
+	assert.NotContains(t, printed, "secret-token")
+	assert.Contains(t, printed, redacted)
+}
+
+func Test_LoadInvalid(t *testing.T) {
+	t.Setenv("CACHE_TTL", "an hour")
+	t.Setenv("DB_FILE", "")
+	t.Setenv("PRESEED", "")
+
+	_, err := Load(writeConfig(t, testConfig))
+	assert.ErrorContains(t, err, "CACHE_TTL")
+
+	t.Setenv("CACHE_TTL", "")
+	_, err = Load(writeConfig(t, testConfig+"\n[store]\nmode = \"everything\"\n"))
+	assert.ErrorContains(t, err, "store.mode")
+
+	_, err = Load(writeConfig(t, "unknown = true\n"))
+	assert.ErrorContains(t, err, "unknown")
+
+	_, err = Load(writeConfig(t, `
+[database]
+engine = "postgres"
+
+[[preseed]]
+project = 1
+loaders = ["rift"]
+`))
+	assert.ErrorContains(t, err, "preseed[0].mod_id")
+	assert.ErrorContains(t, err, "preseed[0].loaders")
+
+	//the database is only checked by the commands which use it, and an empty sqlite3 file is still allowed
+	assert.ErrorContains(t, Database{Engine: "postgres"}.Validate(), "database.engine")
+	assert.ErrorContains(t, Database{Engine: "mysql"}.Validate(), "database.host")
+	assert.NoError(t, Default().Database.Validate())
+
+	_, err = Load(writeConfig(t, `
+[promotion.default]
+latest = "nightly"
+
+[promotion.projects.jei]
+pinned = { "1.20.1" = "1.0.0" }
+`))
+	assert.ErrorContains(t, err, "promotion.default.latest")
+	assert.ErrorContains(t, err, "promotion.projects.jei: must be a project id")
+	assert.ErrorContains(t, err, "promotion.projects.jei.pinned")
+}
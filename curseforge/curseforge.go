@@ -8,25 +8,47 @@ import (
 	"math/rand"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
-	"github.com/cfwidget/updatejson/env"
 	"github.com/cfwidget/updatejson/logger"
 )
 
-const BaseUrl string = "https://api.curseforge.com/v1/"
+// BaseUrl is where the API is called, which tests can point at their own server
+var BaseUrl = "https://api.curseforge.com/v1/"
+
 const PageSize = 50
 const OverflowedPageSize = 20
 
+const ClassMods = 6
+const ClassResourcePacks = 12
+const ClassDataPacks = 6945
+
 var ErrUnsupportedGame = errors.New("unsupported game")
 var ErrInvalidProjectId = errors.New("invalid project id")
 var ErrUnauthorized = errors.New("unauthorized")
+var ErrInvalidFileId = errors.New("invalid file id")
+
+const HashAlgoSha1 = 1
+
 var _client *http.Client
+var apiKey string
+var debug bool
+
+var minecraftVersions []string
+var minecraftVersionsExpireAt time.Time
+var minecraftVersionsLock sync.Mutex
 
 func init() {
 	_client = &http.Client{}
 }
 
+// Initialize sets the API key requests are made with, and if they are logged
+func Initialize(key string, debugRequests bool) {
+	apiKey = key
+	debug = debugRequests
+}
+
 func GetProject(projectId uint, ctx context.Context) (Project, error) {
 	response, err := Call(fmt.Sprintf("mods/%d", projectId), ctx)
 	if err != nil {
@@ -50,6 +72,29 @@ func GetProject(projectId uint, ctx context.Context) (Project, error) {
 	return project.Data, err
 }
 
+// GetProjectBySlug finds the Minecraft project with the slug used in its CurseForge page
+func GetProjectBySlug(slug string, ctx context.Context) (Project, error) {
+	response, err := Call(fmt.Sprintf("mods/search?gameId=432&slug=%s", url.QueryEscape(slug)), ctx)
+	if err != nil {
+		return Project{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return Project{}, ErrUnauthorized
+	}
+
+	var projects SearchResponse
+	err = json.NewDecoder(response.Body).Decode(&projects)
+	if err != nil {
+		return Project{}, err
+	}
+	if len(projects.Data) == 0 {
+		return Project{}, ErrInvalidProjectId
+	}
+	return projects.Data[0], nil
+}
+
 func GetFilesForProject(projectId uint, ctx context.Context) ([]File, error) {
 	files := make([]File, 0)
 	page := uint(0)
@@ -75,6 +120,60 @@ func GetFilesForProject(projectId uint, ctx context.Context) ([]File, error) {
 	return files, nil
 }
 
+func GetFile(projectId uint, fileId uint, ctx context.Context) (File, error) {
+	response, err := Call(fmt.Sprintf("mods/%d/files/%d", projectId, fileId), ctx)
+	if err != nil {
+		return File{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return File{}, ErrInvalidFileId
+	}
+	if response.StatusCode != http.StatusOK {
+		return File{}, ErrUnauthorized
+	}
+
+	var file SingleFileResponse
+	err = json.NewDecoder(response.Body).Decode(&file)
+	return file.Data, err
+}
+
+// GetMinecraftVersions returns every Minecraft version CurseForge knows about. These rarely change, so they are kept
+// in memory for an hour.
+func GetMinecraftVersions(ctx context.Context) ([]string, error) {
+	minecraftVersionsLock.Lock()
+	defer minecraftVersionsLock.Unlock()
+
+	if minecraftVersions != nil && time.Now().Before(minecraftVersionsExpireAt) {
+		return minecraftVersions, nil
+	}
+
+	response, err := Call("minecraft/version", ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, ErrUnauthorized
+	}
+
+	var versions MinecraftVersionResponse
+	err = json.NewDecoder(response.Body).Decode(&versions)
+	if err != nil {
+		return nil, err
+	}
+
+	minecraftVersions = make([]string, 0, len(versions.Data))
+	for _, v := range versions.Data {
+		minecraftVersions = append(minecraftVersions, v.VersionString)
+	}
+	minecraftVersionsExpireAt = time.Now().Add(time.Hour)
+
+	return minecraftVersions, nil
+}
+
 func getFilesForPage(projectId, page uint, ctx context.Context) (FileResponse, error) {
 	response, err := Call(fmt.Sprintf("mods/%d/files?index=%d&pageSize=%d", projectId, page*PageSize, PageSize), ctx)
 	if err != nil {
@@ -96,7 +195,7 @@ func getFilesForPage(projectId, page uint, ctx context.Context) (FileResponse, e
 }
 
 func Call(requestUri string, ctx context.Context) (*http.Response, error) {
-	key := env.Get("CORE_KEY")
+	key := apiKey
 
 	path, err := url.Parse(BaseUrl + requestUri)
 	if err != nil {
@@ -111,14 +210,14 @@ func Call(requestUri string, ctx context.Context) (*http.Response, error) {
 	request.Header.Add("x-api-key", key)
 
 	response, err := _client.Do(request.WithContext(ctx))
-	if env.GetBool("DEBUG") {
+	if debug {
 		logger.FromContext(ctx).Printf("[GET] [%d] %s\n", response.StatusCode, path.String())
 	}
 	if response.StatusCode == http.StatusTooManyRequests {
 		_ = response.Body.Close()
 		time.Sleep(time.Duration(rand.Intn(30)+5) * time.Second)
 		response, err = _client.Do(request.WithContext(ctx))
-		if env.GetBool("DEBUG") {
+		if debug {
 			logger.FromContext(ctx).Printf("[GET] [%d] %s\n", response.StatusCode, path.String())
 		}
 	}
@@ -137,10 +236,10 @@ func DownloadFile(requestUrl string, ctx context.Context) (*http.Response, error
 		URL:    path,
 		Header: http.Header{},
 	}
-	request.Header.Add("x-api-key", env.Get("CORE_KEY"))
+	request.Header.Add("x-api-key", apiKey)
 
 	response, err := _client.Do(request.WithContext(ctx))
-	if env.GetBool("DEBUG") {
+	if debug {
 		logger.FromContext(ctx).Printf("[GET] [%d] %s\n", response.StatusCode, path.String())
 	}
 	return response, err
@@ -155,13 +254,36 @@ type FileResponse struct {
 	Pagination Pagination
 }
 
+type SingleFileResponse struct {
+	Response
+	Data File
+}
+
 type ProjectResponse struct {
 	Response
 	Data Project
 }
 
+type SearchResponse struct {
+	Response
+	Data       []Project
+	Pagination Pagination
+}
+
+type MinecraftVersionResponse struct {
+	Response
+	Data []MinecraftVersion
+}
+
+type MinecraftVersion struct {
+	Id            uint
+	VersionString string
+}
+
 type File struct {
 	Id           uint
+	DisplayName  string
+	FileName     string
 	FileDate     time.Time
 	DownloadUrl  string
 	ReleaseType  int8
@@ -169,12 +291,24 @@ type File struct {
 	IsAvailable  bool
 	GameVersions []string
 	Modules      []Module
+	Hashes       []FileHash
+}
+
+type FileHash struct {
+	Value string
+	Algo  int
 }
 
 type Project struct {
-	Id     uint
-	GameId int
-	Links  Links
+	Id      uint
+	GameId  int
+	ClassId int
+	Slug    string
+	Links   Links
+}
+
+func (p Project) IsPack() bool {
+	return p.ClassId == ClassResourcePacks || p.ClassId == ClassDataPacks
 }
 
 type Links struct {
@@ -7,7 +7,7 @@ import (
 	"log"
 	"time"
 
-	"github.com/cfwidget/updatejson/env"
+	"github.com/cfwidget/updatejson/config"
 	"github.com/cfwidget/updatejson/models"
 	"github.com/go-gormigrate/gormigrate/v2"
 	"gorm.io/driver/mysql"
@@ -17,23 +17,23 @@ import (
 
 var _db *gorm.DB
 
-func Initialize() {
+func Initialize(cfg config.Database) {
 	var err error
 
-	switch env.GetOr("DB_ENGINE", "sqlite3") {
+	switch cfg.Engine {
 	case "mysql":
 		{
-			dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", env.Get("DB_USER"), env.Get("DB_PASS"), env.Get("DB_HOST"), env.Get("DB_DATABASE"))
+			dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", cfg.User, cfg.Pass, cfg.Host, cfg.Database)
 			_db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
 		}
 	case "sqlite3":
 		{
-			dsn := env.Get("DB_FILE")
+			dsn := cfg.File
 			_db, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 		}
 	default:
 		{
-			err = errors.New("unsupported DB_ENGINE (one of: sqlite3, mysql), got " + env.Get("DB_ENGINE"))
+			err = errors.New("unsupported DB_ENGINE (one of: sqlite3, mysql), got " + cfg.Engine)
 		}
 	}
 
@@ -47,12 +47,12 @@ func Initialize() {
 	sqlDB.SetMaxOpenConns(10)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	if env.Get("DB_MODE") != "release" {
+	if cfg.Mode != "release" {
 		_db = _db.Debug()
 		log.Println("Set DB_MODE to 'release' to disable debug database logger")
 	}
 
-	err = _db.AutoMigrate(&models.Version{})
+	err = _db.AutoMigrate(&models.Version{}, &models.VersionDependency{}, &models.FileFailure{}, &models.PromoSnapshot{})
 	if err != nil {
 		log.Panicf("Error running DB migration: %s", err.Error())
 	}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/cfwidget/updatejson/database"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+const (
+	decisionPromoted  = "promoted"
+	decisionOutranked = "outranked"
+	decisionSkipped   = "skipped"
+	decisionUnused    = "unused"
+	decisionError     = "error"
+)
+
+// getDebugReport explains how the promos for a mod were picked. This is never cached, since it is used to find out
+// why the cached response looks the way it does.
+func getDebugReport(c *gin.Context) {
+	pid := c.Param("projectId")
+	modId := c.Param("modId")
+	loader := getLoader(c)
+	ctx := c.Request.Context()
+
+	c.Header("Cache-Control", "no-store")
+
+	var projectId uint
+	var err error
+	if projectId, err = cast.ToUintE(pid); err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	opts := getUpdateOptions(c)
+	if err = opts.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	project, versionMap, fileErrors, err := getProjectVersions(projectId, ctx)
+	if isBadRequest(err) {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	} else if err != nil {
+		logger.Printf(ctx, "Error: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	failures, err := getProjectFailures(project.Id, ctx)
+	if err != nil {
+		logger.Printf(ctx, "Error: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	trace := newPromoTrace()
+	promos := tracePromos(project, versionMap, modId, loader, opts, trace, ctx)
+
+	c.JSON(http.StatusOK, buildDebugReport(promos, versionMap, fileErrors, failures, trace))
+}
+
+func buildDebugReport(promos *models.UpdateJson, versionMap map[VersionKey]*models.Version, fileErrors map[uint]error, failures map[uint]*models.FileFailure, trace *promoTrace) models.DebugReport {
+	report := models.DebugReport{
+		Promos: promos.Promos,
+		Files:  make([]models.FileDecision, 0, len(versionMap)+len(fileErrors)),
+	}
+
+	for _, v := range versionMap {
+		decision := models.FileDecision{
+			FileId:              v.FileId,
+			ModId:               v.ModId,
+			Version:             v.Version,
+			Loader:              v.Loader,
+			GameVersions:        strings.Split(v.GameVersions, ","),
+			DroppedGameVersions: trace.dropped[v],
+		}
+
+		if reason, skipped := trace.skipped[v]; skipped {
+			decision.Decision = decisionSkipped
+			decision.Reason = reason
+			report.Files = append(report.Files, decision)
+			continue
+		}
+
+		for _, key := range trace.competed[v] {
+			winner := promos.Versions[key]
+			if winner == v {
+				decision.Won = append(decision.Won, key)
+			} else if winner != nil {
+				decision.Lost = append(decision.Lost, models.LostKey{Key: key, FileId: winner.FileId, ModId: winner.ModId, Version: winner.Version})
+			}
+		}
+
+		switch {
+		case len(decision.Won) > 0:
+			decision.Decision = decisionPromoted
+		case len(decision.Lost) > 0:
+			decision.Decision = decisionOutranked
+		default:
+			decision.Decision = decisionUnused
+			decision.Reason = "no usable game versions"
+		}
+		slices.Sort(decision.Won)
+		slices.SortFunc(decision.Lost, func(a, b models.LostKey) int { return cmp.Compare(a.Key, b.Key) })
+
+		report.Files = append(report.Files, decision)
+	}
+
+	for fileId, err := range fileErrors {
+		report.Files = append(report.Files, models.FileDecision{
+			FileId:       fileId,
+			GameVersions: make([]string, 0),
+			Decision:     decisionError,
+			Reason:       err.Error(),
+			Failure:      failures[fileId],
+		})
+	}
+
+	//newest files first
+	slices.SortFunc(report.Files, func(a, b models.FileDecision) int {
+		return cmp.Or(cmp.Compare(b.FileId, a.FileId), cmp.Compare(a.ModId, b.ModId))
+	})
+
+	return report
+}
+
+// getProjectFailures loads the recorded failures of every file of the project
+func getProjectFailures(projectId uint, ctx context.Context) (map[uint]*models.FileFailure, error) {
+	db, err := database.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []*models.FileFailure
+	err = db.Where(&models.FileFailure{CurseId: projectId}).Find(&failures).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint]*models.FileFailure)
+	for _, v := range failures {
+		result[v.FileId] = v
+	}
+	return result, nil
+}
+
+// getSkipReason checks if a version can be used for the requested mod and loader, returning why not if it can't
+func getSkipReason(version *models.Version, modId string, loader string) string {
+	switch {
+	case version.ModId == "":
+		return "no mod found in file"
+	case !matchesModId(version, modId):
+		return fmt.Sprintf("mod id %s does not match", version.ModId)
+	case version.Version == "":
+		return "version is empty"
+	case !supportsLoader(version.Loader, loader):
+		return fmt.Sprintf("loader %s does not match %s", version.Loader, loader)
+	}
+	return ""
+}
+
+// promoTrace records the decisions made while picking promos. A nil trace records nothing.
+type promoTrace struct {
+	skipped  map[*models.Version]string
+	dropped  map[*models.Version][]string
+	competed map[*models.Version][]string
+}
+
+func newPromoTrace() *promoTrace {
+	return &promoTrace{
+		skipped:  make(map[*models.Version]string),
+		dropped:  make(map[*models.Version][]string),
+		competed: make(map[*models.Version][]string),
+	}
+}
+
+func (t *promoTrace) skip(version *models.Version, reason string) {
+	if t != nil {
+		t.skipped[version] = reason
+	}
+}
+
+func (t *promoTrace) drop(version *models.Version, gameVersion string) {
+	if t != nil {
+		t.dropped[version] = append(t.dropped[version], gameVersion)
+	}
+}
+
+func (t *promoTrace) compete(version *models.Version, key string) {
+	if t != nil {
+		t.competed[version] = append(t.competed[version], key)
+	}
+}
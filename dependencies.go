@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/cfwidget/updatejson/cache"
+	"github.com/cfwidget/updatejson/database"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+)
+
+func getDependencies(c *gin.Context) {
+	pid := c.Param("projectId")
+	modId := c.Param("modId")
+	loader := getLoader(c)
+	mcVersion := c.Query("mc")
+
+	cacheKey := cache.GetKey(c)
+
+	var projectId uint
+	var err error
+	if projectId, err = cast.ToUintE(pid); err != nil {
+		cache.Set(cacheKey, http.StatusNotFound, nil)
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if mcVersion == "" {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": "mc version is required"})
+		return
+	}
+
+	var data *models.UpdateJson
+	data, err = getUpdateJson(projectId, modId, loader, getUpdateOptions(c), c.Request.Context())
+
+	var deps map[string]*models.DependencyInfo
+	if err == nil && data != nil {
+		deps, err = getPromoDependencies(data, mcVersion, c.Request.Context())
+	}
+
+	if isBadRequest(err) {
+		d := map[string]string{"error": err.Error()}
+		cache.Set(cacheKey, http.StatusBadRequest, d)
+		c.JSON(http.StatusBadRequest, d)
+	} else if err != nil {
+		logger.Printf(c.Request.Context(), "Error: %s", err.Error())
+		d := map[string]string{"error": err.Error()}
+		cacheExpireTime := cache.Set(cacheKey, http.StatusInternalServerError, d)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.Status(http.StatusInternalServerError)
+	} else if data != nil {
+		cacheExpireTime := cache.Set(cacheKey, http.StatusOK, deps)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.JSON(http.StatusOK, deps)
+	} else {
+		cacheExpireTime := cache.Set(cacheKey, http.StatusNotFound, nil)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.Status(http.StatusNotFound)
+	}
+}
+
+// getPromoDependencies loads the stored dependencies for the latest and recommended versions of the given MC version
+func getPromoDependencies(data *models.UpdateJson, mcVersion string, ctx context.Context) (map[string]*models.DependencyInfo, error) {
+	db, err := database.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*models.DependencyInfo)
+	for _, promo := range []string{"latest", "recommended"} {
+		version, exists := data.Versions[mcVersion+"-"+promo]
+		if !exists || version == nil {
+			continue
+		}
+
+		info := &models.DependencyInfo{
+			Version:      version.Version,
+			Url:          version.Url,
+			Dependencies: make([]models.VersionDependency, 0),
+		}
+
+		err = db.Where(&models.VersionDependency{VersionId: version.Id}).Order("id").Find(&info.Dependencies).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		result[promo] = info
+	}
+
+	return result, nil
+}
+
+func saveDependencies(db *gorm.DB, versionId uint, deps []models.Dependency) error {
+	for _, v := range deps {
+		err := db.Create(&models.VersionDependency{
+			VersionId:    versionId,
+			ModId:        v.ModId,
+			Type:         v.Type,
+			Mandatory:    v.Mandatory,
+			VersionRange: v.VersionRange,
+			Side:         v.Side,
+			Ordering:     v.Ordering,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeDependencies fills in the fields that differ between the Forge and NeoForge formats, so both can be read
+// the same way. Forge uses "mandatory" while NeoForge uses "type".
+func normalizeDependencies(deps map[string][]models.Dependency) {
+	for _, list := range deps {
+		for k, v := range list {
+			v.Type = strings.ToLower(v.Type)
+			if v.Type == "" {
+				v.Type = "optional"
+				if v.Mandatory {
+					v.Type = "required"
+				}
+			}
+			v.Mandatory = v.Type == "required"
+
+			if v.Side == "" {
+				v.Side = "BOTH"
+			}
+			if v.Ordering == "" {
+				v.Ordering = "NONE"
+			}
+			list[k] = v
+		}
+	}
+}
+
+// fabricDependencies converts the depends-style blocks of a fabric.mod.json into dependencies.
+// Fabric has no concept of ordering or sides for dependencies, so the Forge defaults are used.
+func fabricDependencies(mod models.FabricMod) []models.Dependency {
+	deps := make([]models.Dependency, 0)
+
+	blocks := []struct {
+		Type    string
+		Entries map[string]models.StringList
+	}{
+		{Type: "required", Entries: mod.Depends},
+		{Type: "optional", Entries: mod.Recommends},
+		{Type: "optional", Entries: mod.Suggests},
+		{Type: "incompatible", Entries: mod.Breaks},
+		{Type: "discouraged", Entries: mod.Conflicts},
+	}
+
+	for _, block := range blocks {
+		ids := make([]string, 0, len(block.Entries))
+		for id := range block.Entries {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+
+		for _, id := range ids {
+			deps = append(deps, models.Dependency{
+				ModId:        id,
+				Mandatory:    block.Type == "required",
+				Type:         block.Type,
+				VersionRange: strings.Join(block.Entries[id], " || "),
+				Ordering:     "NONE",
+				Side:         "BOTH",
+			})
+		}
+	}
+
+	return deps
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/cfwidget/updatejson/models"
+)
+
+// formats the promos can be written in
+const (
+	formatForge    = ""
+	formatExtended = "extended"
+)
+
+var ErrInvalidFormat = errors.New("invalid format, must be extended or left out")
+
+// newExtendedUpdateJson adds the details of the file behind each promo key to the update JSON
+func newExtendedUpdateJson(data *models.UpdateJson) models.ExtendedUpdateJson {
+	result := models.ExtendedUpdateJson{
+		Promos:      data.Promos,
+		Files:       make(map[string]models.PromoFile, len(data.Versions)),
+		HomePage:    data.HomePage,
+		Diagnostics: data.Diagnostics,
+	}
+
+	for k, v := range data.Versions {
+		result.Files[k] = models.PromoFile{
+			Version:     v.Version,
+			FileId:      v.FileId,
+			FileName:    v.FileName,
+			ReleaseType: getReleaseTypeName(v.Type),
+			ReleaseDate: v.ReleaseDate,
+			Url:         v.Url,
+			DownloadUrl: v.DownloadUrl,
+		}
+	}
+
+	return result
+}
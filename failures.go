@@ -0,0 +1,96 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"expvar"
+	"math"
+	"time"
+
+	"github.com/cfwidget/updatejson/config"
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"gorm.io/gorm"
+)
+
+var ErrRetryLater = errors.New("file failed recently, waiting to retry")
+var errDownloadFailed = errors.New("download failed")
+
+const (
+	failureDownload = "download"
+	failureCorrupt  = "corrupt"
+	failureLimit    = "limit"
+	failureDatabase = "database"
+	failureUnknown  = "unknown"
+)
+
+var (
+	metricFailures          = expvar.NewInt("file_failures")
+	metricPermanentFailures = expvar.NewInt("file_failures_permanent")
+	metricRetriesSkipped    = expvar.NewInt("file_retries_skipped")
+)
+
+// how long to wait after the first failure, doubled for each failure after until it reaches the max
+var failureBackoff = time.Duration(config.Default().Failures.Backoff)
+var failureMaxBackoff = time.Duration(config.Default().Failures.MaxBackoff)
+
+// classifyFailure works out what kind of error a file hit, and if trying again could ever fix it. A corrupt archive,
+// or one over the limits, will not change, so it is not retried.
+func classifyFailure(err error) (string, bool) {
+	switch {
+	case errors.Is(err, zip.ErrFormat), errors.Is(err, zip.ErrAlgorithm), errors.Is(err, zip.ErrChecksum):
+		return failureCorrupt, true
+	case errors.Is(err, ErrArchiveLimit):
+		return failureLimit, true
+	case errors.Is(err, errDownloadFailed), errors.Is(err, context.DeadlineExceeded):
+		return failureDownload, false
+	case errors.Is(err, gorm.ErrInvalidDB), errors.Is(err, gorm.ErrInvalidTransaction):
+		return failureDatabase, false
+	}
+	return failureUnknown, false
+}
+
+func getBackoff(attempts int) time.Duration {
+	backoff := float64(failureBackoff) * math.Pow(2, float64(attempts-1))
+	if backoff > float64(failureMaxBackoff) {
+		return failureMaxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+func getFileFailure(db *gorm.DB, fileId uint) (*models.FileFailure, error) {
+	var failures []*models.FileFailure
+	err := db.Where(&models.FileFailure{FileId: fileId}).Limit(1).Find(&failures).Error
+	if err != nil || len(failures) == 0 {
+		return nil, err
+	}
+	return failures[0], nil
+}
+
+// recordFileFailure stores the failure of a file so it is not retried until its backoff elapses. The original error
+// is returned so callers can pass it along.
+func recordFileFailure(db *gorm.DB, project curseforge.Project, curseFile curseforge.File, failure *models.FileFailure, cause error, ctx context.Context) error {
+	if failure == nil {
+		failure = &models.FileFailure{CurseId: project.Id, FileId: curseFile.Id}
+	}
+
+	failure.ErrorClass, failure.Permanent = classifyFailure(cause)
+	failure.Message = cause.Error()
+	if len(failure.Message) > 500 {
+		failure.Message = failure.Message[:500]
+	}
+	failure.Attempts++
+	failure.NextRetry = time.Now().Add(getBackoff(failure.Attempts))
+
+	metricFailures.Add(1)
+	if failure.Permanent {
+		metricPermanentFailures.Add(1)
+	}
+
+	if err := db.Save(failure).Error; err != nil {
+		logger.Printf(ctx, "Failed to record failure of file %d: %s", curseFile.Id, err)
+	}
+	return cause
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cfwidget/updatejson/database"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// the hash of the promos last recorded for each mod and loader, so unchanged promos don't touch the database
+var recordedPromos sync.Map
+
+// recordSnapshot stores the promos of the mod and loader if they differ from the last ones stored
+func recordSnapshot(projectId uint, modId string, loader string, promos map[string]string, ctx context.Context) error {
+	data, err := json.Marshal(promos)
+	if err != nil {
+		return err
+	}
+	hash := sha1.Sum(data)
+	key := fmt.Sprintf("%d/%s/%s", projectId, modId, loader)
+	if recorded, exists := recordedPromos.Load(key); exists && recorded == hash {
+		return nil
+	}
+
+	db, err := database.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	var last models.PromoSnapshot
+	err = db.Where(&models.PromoSnapshot{CurseId: projectId, ModId: modId, Loader: loader}).Order("id desc").Limit(1).Find(&last).Error
+	if err != nil {
+		return err
+	}
+
+	//mod ids which never had promos are not worth remembering
+	if (last.Id == 0 && len(promos) == 0) || (last.Id != 0 && last.Promos == string(data)) {
+		recordedPromos.Store(key, hash)
+		return nil
+	}
+
+	//each snapshot follows on from the last, so if another request records the same change first, this one is dropped
+	err = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PromoSnapshot{
+		CurseId:    projectId,
+		ModId:      modId,
+		Loader:     loader,
+		PreviousId: &last.Id,
+		Promos:     string(data),
+		CreatedAt:  time.Now(),
+	}).Error
+	if err != nil {
+		return err
+	}
+	recordedPromos.Store(key, hash)
+	return nil
+}
+
+// getSnapshots returns the snapshots of the mod and loader in the order they were taken. If since is given, only the
+// ones taken after it are returned, plus the one which was current at that time.
+func getSnapshots(projectId uint, modId string, loader string, since time.Time, ctx context.Context) ([]*models.PromoSnapshot, error) {
+	db, err := database.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.Where(&models.PromoSnapshot{CurseId: projectId, ModId: modId, Loader: loader})
+
+	var snapshots []*models.PromoSnapshot
+	if !since.IsZero() {
+		var current models.PromoSnapshot
+		err = query.Session(&gorm.Session{}).Where("created_at <= ?", since).Order("id desc").Limit(1).Find(&current).Error
+		if err != nil {
+			return nil, err
+		}
+		if current.Id != 0 {
+			snapshots = append(snapshots, &current)
+		}
+		query = query.Where("created_at > ?", since)
+	}
+
+	var after []*models.PromoSnapshot
+	err = query.Order("id asc").Find(&after).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return append(snapshots, after...), nil
+}
+
+// getPromoChanges lists every change between one snapshot and the next, starting from the given promos
+func getPromoChanges(snapshots []*models.PromoSnapshot, promos map[string]string) ([]models.PromoChange, error) {
+	changes := make([]models.PromoChange, 0)
+
+	for _, snapshot := range snapshots {
+		var next map[string]string
+		if err := json.Unmarshal([]byte(snapshot.Promos), &next); err != nil {
+			return nil, err
+		}
+
+		for k, v := range next {
+			if promos[k] != v {
+				changes = append(changes, models.PromoChange{Key: k, From: promos[k], To: v, ChangedAt: snapshot.CreatedAt})
+			}
+		}
+		for k, v := range promos {
+			if _, exists := next[k]; !exists {
+				changes = append(changes, models.PromoChange{Key: k, From: v, ChangedAt: snapshot.CreatedAt})
+			}
+		}
+
+		promos = next
+	}
+
+	return changes, nil
+}
+
+// getPromoHistory lists every change made to the promos of the mod, newest first
+func getPromoHistory(c *gin.Context) {
+	pid := c.Param("projectId")
+	modId := c.Param("modId")
+	loader := getLoader(c)
+	ctx := c.Request.Context()
+
+	c.Header("Cache-Control", "no-store")
+
+	projectId, err := cast.ToUintE(pid)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	snapshots, err := getSnapshots(projectId, modId, loader, time.Time{}, ctx)
+	var changes []models.PromoChange
+	if err == nil {
+		changes, err = getPromoChanges(snapshots, nil)
+	}
+	if err != nil {
+		logger.Printf(ctx, "Error: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	slices.SortStableFunc(changes, func(a, b models.PromoChange) int {
+		if c := b.ChangedAt.Compare(a.ChangedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+	c.JSON(http.StatusOK, changes)
+}
+
+// getPromoDiff lists each promo key which differs now from what it was at the requested time
+func getPromoDiff(c *gin.Context) {
+	pid := c.Param("projectId")
+	modId := c.Param("modId")
+	loader := getLoader(c)
+	ctx := c.Request.Context()
+
+	c.Header("Cache-Control", "no-store")
+
+	projectId, err := cast.ToUintE(pid)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	since, err := time.Parse(time.RFC3339, c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": ErrInvalidTime.Error()})
+		return
+	}
+
+	snapshots, err := getSnapshots(projectId, modId, loader, since, ctx)
+	var changes []models.PromoChange
+	if err == nil {
+		//the snapshot current at that time is the starting point rather than a change
+		var promos map[string]string
+		if len(snapshots) > 0 && !snapshots[0].CreatedAt.After(since) {
+			err = json.Unmarshal([]byte(snapshots[0].Promos), &promos)
+			snapshots = snapshots[1:]
+		}
+		if err == nil {
+			changes, err = getPromoChanges(snapshots, promos)
+		}
+	}
+	if err != nil {
+		logger.Printf(ctx, "Error: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, squashPromoChanges(changes))
+}
+
+// squashPromoChanges merges the changes to each key into one, going from the first value to the last, and drops keys
+// which ended up where they started
+func squashPromoChanges(changes []models.PromoChange) []models.PromoChange {
+	squashed := make(map[string]models.PromoChange)
+	for _, v := range changes {
+		if existing, exists := squashed[v.Key]; exists {
+			v.From = existing.From
+		}
+		squashed[v.Key] = v
+	}
+
+	result := make([]models.PromoChange, 0, len(squashed))
+	for _, v := range squashed {
+		if v.From != v.To {
+			result = append(result, v)
+		}
+	}
+	slices.SortFunc(result, func(a, b models.PromoChange) int { return cmp.Compare(a.Key, b.Key) })
+	return result
+}
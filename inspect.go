@@ -0,0 +1,110 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/cfwidget/updatejson/models"
+	"github.com/gin-gonic/gin"
+)
+
+// inspectJar parses an uploaded jar the same way files from CurseForge are, so authors can check what we will read
+// from a build before uploading it
+func inspectJar(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if c.Request.ContentLength > limits.DownloadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": ErrArchiveLimit.Error()})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.DownloadSize)
+
+	upload, err := c.FormFile("file")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": ErrArchiveLimit.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": "jar must be uploaded as the file field"})
+		return
+	}
+
+	file, err := upload.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	r, err := zip.NewReader(file, upload.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	result, err := inspectUpload(r, ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func inspectUpload(r *zip.Reader, ctx context.Context) (*models.InspectResult, error) {
+	modInfo, entryErrors, err := inspectJarFile(r, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestVersion, err := getManifestVersion(r)
+	if errors.Is(err, ErrArchiveLimit) {
+		return nil, err
+	} else if err != nil {
+		entryErrors = append(entryErrors, models.EntryError{Entry: "META-INF/MANIFEST.MF", Error: err.Error()})
+	}
+
+	result := &models.InspectResult{
+		Mods:            make([]models.InspectedMod, 0),
+		Loaders:         make([]string, 0),
+		ManifestVersion: manifestVersion,
+		Errors:          entryErrors,
+	}
+	if modInfo == nil {
+		return result, nil
+	}
+
+	declared := make([]string, len(modInfo.Mods))
+	for k, v := range modInfo.Mods {
+		declared[k] = v.Version
+	}
+	applyManifestVersion(modInfo, manifestVersion)
+
+	result.Loaders = strings.Split(modInfo.ModLoader, ",")
+	for k, v := range modInfo.Mods {
+		provides := make([]string, 0)
+		if v.Provides != "" {
+			provides = strings.Split(v.Provides, ",")
+		}
+		dependencies := modInfo.Dependencies[v.ModId]
+		if dependencies == nil {
+			dependencies = make([]models.Dependency, 0)
+		}
+
+		result.Mods = append(result.Mods, models.InspectedMod{
+			ModId:           v.ModId,
+			Version:         v.Version,
+			DeclaredVersion: declared[k],
+			DisplayName:     v.DisplayName,
+			Side:            v.Side,
+			Provides:        provides,
+			McVersionRange:  getMinecraftRange(dependencies),
+			Dependencies:    dependencies,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/store"
+	"github.com/cfwidget/updatejson/util"
+)
+
+// entries kept when the store only holds metadata, which is everything the parsers read
+var metadataEntries = []string{
+	"META-INF/mods.toml",
+	"META-INF/neoforge.mods.toml",
+	"META-INF/MANIFEST.MF",
+	"fabric.mod.json",
+	"quilt.mod.json",
+	"mcmod.info",
+	"pack.mcmeta",
+}
+
+// openFile opens a file from the jar store, or downloads it from CurseForge and adds it to the store
+func openFile(curseFile curseforge.File, ctx context.Context) (io.ReaderAt, int64, io.Closer, error) {
+	hash := getFileHash(curseFile)
+	if store.Enabled() && hash != "" {
+		f, size, err := store.Get(hash)
+		if err == nil {
+			return f, size, f, nil
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			logger.Printf(ctx, "Failed to read file %d from store: %s", curseFile.Id, err)
+		}
+	}
+
+	reader, size, err := downloadFile(curseFile.DownloadUrl, ctx)
+	if err != nil {
+		return nil, 0, reader, err
+	}
+
+	//without a hash from CurseForge the file could never be found in the store again
+	if store.Enabled() && hash != "" {
+		if err = storeFile(hash, reader, size); err != nil {
+			logger.Printf(ctx, "Failed to add file %d to store: %s", curseFile.Id, err)
+		}
+	}
+
+	return reader, size, reader, nil
+}
+
+// getFileHash returns the sha1 CurseForge has for the file, which is what the store is keyed by
+func getFileHash(curseFile curseforge.File) string {
+	for _, v := range curseFile.Hashes {
+		if v.Algo == curseforge.HashAlgoSha1 {
+			return strings.ToLower(v.Value)
+		}
+	}
+	return ""
+}
+
+// storeFile writes a downloaded file to the store, checking it against the hash CurseForge gave first
+func storeFile(expected string, reader *util.TempFile, size int64) error {
+	hash := sha1.New()
+	_, err := io.Copy(hash, io.NewSectionReader(reader, 0, size))
+	if err != nil {
+		return err
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if actual != expected {
+		return errors.New("downloaded file does not match hash " + expected)
+	}
+
+	if store.GetMode() == store.ModeMetadata {
+		if data, ok := buildMetadataZip(reader, size); ok {
+			return store.Put(actual, bytes.NewReader(data))
+		}
+	}
+
+	return store.Put(actual, io.NewSectionReader(reader, 0, size))
+}
+
+// buildMetadataZip copies the metadata entries, and any nested packs, into a new zip. If there are none, such as for
+// mods only described by their @Mod annotation, the whole file has to be kept instead. The manifest alone doesn't
+// count, since nearly every jar has one.
+func buildMetadataZip(reader io.ReaderAt, size int64) ([]byte, bool) {
+	r, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, false
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	copied := 0
+	for _, f := range r.File {
+		if !isMetadataEntry(f.Name) {
+			continue
+		}
+		if err = w.Copy(f); err != nil {
+			return nil, false
+		}
+		if f.Name != "META-INF/MANIFEST.MF" {
+			copied++
+		}
+	}
+	if err = w.Close(); err != nil || copied == 0 {
+		return nil, false
+	}
+
+	return buf.Bytes(), true
+}
+
+func isMetadataEntry(name string) bool {
+	for _, v := range metadataEntries {
+		if name == v {
+			return true
+		}
+	}
+	return strings.HasSuffix(strings.ToLower(name), ".zip")
+}
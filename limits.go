@@ -0,0 +1,60 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+
+	"github.com/cfwidget/updatejson/config"
+)
+
+var ErrArchiveLimit = errors.New("archive exceeds limits")
+
+// archiveLimits protect the downloaders from files built to exhaust memory or disk when opened
+type archiveLimits struct {
+	DownloadSize     int64
+	Entries          int
+	EntrySize        uint64
+	CompressionRatio uint64
+	NestingDepth     int
+}
+
+// entries smaller than this are not checked against the compression ratio, since small text files can legitimately
+// compress very well
+const minRatioCheckSize = 1024 * 1024
+
+var limits = newArchiveLimits(config.Default().Limits)
+
+func newArchiveLimits(cfg config.Limits) archiveLimits {
+	return archiveLimits{
+		DownloadSize:     cfg.DownloadSize,
+		Entries:          cfg.Entries,
+		EntrySize:        uint64(cfg.EntrySize),
+		CompressionRatio: uint64(cfg.CompressionRatio),
+		NestingDepth:     cfg.NestingDepth,
+	}
+}
+
+// checkArchive verifies the archive does not have more entries than we are willing to look through
+func checkArchive(file *zip.Reader) error {
+	if len(file.File) > limits.Entries {
+		return fmt.Errorf("%w: %d entries, limit is %d", ErrArchiveLimit, len(file.File), limits.Entries)
+	}
+	return nil
+}
+
+// checkEntry verifies an entry is safe to read into memory, based on the sizes in its header. The zip reader fails
+// when an entry holds more data than its header claims, so the header can be trusted here.
+func checkEntry(file *zip.File) error {
+	if file.UncompressedSize64 > limits.EntrySize {
+		return fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrArchiveLimit, file.Name, file.UncompressedSize64, limits.EntrySize)
+	}
+
+	if file.UncompressedSize64 >= minRatioCheckSize {
+		if file.CompressedSize64 == 0 || file.UncompressedSize64/file.CompressedSize64 > limits.CompressionRatio {
+			return fmt.Errorf("%w: %s has a compression ratio over %d", ErrArchiveLimit, file.Name, limits.CompressionRatio)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/cfwidget/updatejson/cache"
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+// getAllLoaders returns the promos for every loader the mod has been released for, keyed by loader
+func getAllLoaders(c *gin.Context) {
+	pid := c.Param("projectId")
+	modId := c.Param("modId")
+
+	cacheKey := cache.GetKey(c)
+
+	var projectId uint
+	var err error
+	if projectId, err = cast.ToUintE(pid); err != nil {
+		cache.Set(cacheKey, http.StatusNotFound, nil)
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	opts := getUpdateOptions(c)
+	var data map[string]*models.UpdateJson
+	data, err = getAllUpdateJson(projectId, modId, opts, c.Request.Context())
+
+	if isBadRequest(err) {
+		d := map[string]string{"error": err.Error()}
+		cache.Set(cacheKey, http.StatusBadRequest, d)
+		c.JSON(http.StatusBadRequest, d)
+	} else if err != nil {
+		logger.Printf(c.Request.Context(), "Error: %s", err.Error())
+		d := map[string]string{"error": err.Error()}
+		cacheExpireTime := cache.Set(cacheKey, http.StatusInternalServerError, d)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.Status(http.StatusInternalServerError)
+	} else {
+		response := allLoadersResponse(data, opts)
+		cacheExpireTime := cache.Set(cacheKey, http.StatusOK, response)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func allLoadersResponse(data map[string]*models.UpdateJson, opts UpdateOptions) map[string]any {
+	response := make(map[string]any, len(data))
+	for loader, v := range data {
+		response[loader] = promosResponse(v, opts)
+	}
+	return response
+}
+
+// getAllUpdateJson builds the promos of every loader found in the project's files for the mod
+func getAllUpdateJson(projectId uint, modId string, opts UpdateOptions, ctx context.Context) (map[string]*models.UpdateJson, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	project, versionMap, _, err := getProjectVersions(projectId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return getAllPromos(project, versionMap, modId, opts, ctx), nil
+}
+
+// getAllPromos builds the promos of every loader found in the versions for the mod
+func getAllPromos(project curseforge.Project, versionMap map[VersionKey]*models.Version, modId string, opts UpdateOptions, ctx context.Context) map[string]*models.UpdateJson {
+	promos := buildPromos(project, versionMap, modId, getModLoaders(versionMap, modId), opts, nil, ctx)
+
+	if opts.isDefault() {
+		for loader, v := range promos {
+			if err := recordSnapshot(project.Id, modId, loader, v.Promos, ctx); err != nil {
+				logger.Printf(ctx, "Failed to record promos: %s", err.Error())
+			}
+		}
+	}
+
+	return promos
+}
+
+// getModLoaders lists the loaders the mod has usable files for. Packs work with any loader, so they only count when
+// the mod has nothing but packs, which is the case for resource and data pack projects.
+func getModLoaders(versionMap map[VersionKey]*models.Version, modId string) []string {
+	loaders := make([]string, 0)
+	packs := make([]string, 0)
+	for _, v := range versionMap {
+		if v.Version == "" || !matchesModId(v, modId) {
+			continue
+		}
+		for _, loader := range strings.Split(strings.ToLower(v.Loader), ",") {
+			if loader == "" {
+				continue
+			}
+			if isPackLoader(loader) {
+				if !slices.Contains(packs, loader) {
+					packs = append(packs, loader)
+				}
+			} else if !slices.Contains(loaders, loader) {
+				loaders = append(loaders, loader)
+			}
+		}
+	}
+	if len(loaders) == 0 {
+		loaders = packs
+	}
+	slices.Sort(loaders)
+	return loaders
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"archive/zip"
+	"cmp"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"expvar"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,8 +16,10 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/cfwidget/updatejson/cache"
+	"github.com/cfwidget/updatejson/config"
 	"github.com/cfwidget/updatejson/curseforge"
 	"github.com/cfwidget/updatejson/database"
 	"github.com/cfwidget/updatejson/logger"
@@ -28,10 +33,20 @@ import (
 
 var invalidGameVersionRegex = regexp.MustCompile("[^0-9.]")
 
+// the domain the service runs on, where subdomains select the loader
+var host string
+
+// version used in a mods.toml to take the version from the jar manifest
+const jarVersionPlaceholder = "${file.jarVersion}"
+
 func main() {
+	os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func serve(cfg *config.Config) {
 	var err error
 
-	database.Initialize()
+	database.Initialize(cfg.Database)
 
 	r := gin.Default()
 
@@ -42,9 +57,23 @@ func main() {
 		ctx.Set(util.GinContextKey, context.WithValue(ctx.Request.Context(), logger.ContextKey, webLogger))
 	})
 
-	r.GET("/:projectId/:modId", readFromCache, processRequest)
-	r.GET("/:projectId/:modId/references", readFromCache, getReferences)
+	r.GET("/:projectId/:modId", servePointInTime(promosResponse), readFromCache, processRequest)
+	r.GET("/:projectId/:modId/references", servePointInTime(referencesResponse), readFromCache, getReferences)
+	r.GET("/:projectId/:modId/all", readFromCache, getAllLoaders)
+	r.GET("/:projectId/:modId/versions", readFromCache, getVersionList)
+	r.GET("/:projectId/:modId/check", readFromCache, checkVersion)
+	r.GET("/:projectId", readFromCache, getProjectPromos)
+	r.GET("/api/projects/:project", readFromCache, getProjectIndex)
+	r.GET("/:projectId/:modId/dependencies", readFromCache, getDependencies)
+	r.GET("/:projectId/:modId/info", readFromCache, getModMetadata)
+	r.GET("/:projectId/:modId/debug", getDebugReport)
+	r.GET("/:projectId/:modId/history", getPromoHistory)
+	r.GET("/:projectId/:modId/diff", getPromoDiff)
 	r.GET("/:projectId/:modId/expire", expireCache)
+	if cfg.MetricsToken != "" {
+		r.GET("/debug/vars", requireToken(cfg.MetricsToken), gin.WrapH(expvar.Handler()))
+	}
+	r.POST("/inspect", inspectJar)
 
 	fs := http.FS(webAssets)
 	r.StaticFileFS("/", "home.html", fs)
@@ -66,34 +95,39 @@ func main() {
 	//to avoid issues at runtime where things started up and we get a request, just pre-seed the records we want before
 	//we accept requests
 
-	for v := range strings.SplitSeq(os.Getenv("PRESEED"), ",") {
-		if v == "" {
+	for _, v := range cfg.Preseed {
+		projectId := v.Project
+		modId := v.ModId
+
+		webLogger.Printf("Preseeding %d:%s", projectId, modId)
+		all, err := getAllUpdateJson(projectId, modId, UpdateOptions{}, context.Background())
+		if err != nil {
+			webLogger.Printf("Error refreshing project: %s", err.Error())
 			continue
 		}
+		_ = cache.Set(fmt.Sprintf("%s/%d/%s/all", host, projectId, modId), http.StatusOK, allLoadersResponse(all, UpdateOptions{}))
 
-		path := strings.Split(v, ":")
-		projectId := cast.ToUint(path[0])
-		modId := path[1]
-
-		for _, loader := range []string{"forge", "fabric", "neoforge", "quilt"} {
-			webLogger.Printf("Preseeding %d:%s (%s)", projectId, modId, loader)
-			data, err := getUpdateJson(projectId, modId, loader, context.Background())
-			if err != nil {
-				webLogger.Printf("Error refreshing project: %s", err.Error())
+		for _, loader := range v.GetLoaders() {
+			data, exists := all[loader]
+			if !exists {
 				continue
 			}
-			_ = cache.Set(fmt.Sprintf("%s.%s/%d/%s", loader, os.Getenv("HOST"), projectId, modId), http.StatusOK, *data)
-			_ = cache.Set(fmt.Sprintf("%s/%d/%s?ml=%s", os.Getenv("HOST"), projectId, modId, loader), http.StatusOK, *data)
-			_ = cache.Set(fmt.Sprintf("forge.%s/%d/%s?ml=%s", os.Getenv("HOST"), projectId, modId, loader), http.StatusOK, *data)
-			_ = cache.Set(fmt.Sprintf("%s.%s/%d/%s?ml=%s", loader, os.Getenv("HOST"), projectId, modId, loader), http.StatusOK, *data)
-
-			_ = cache.Set(fmt.Sprintf("%s.%s/%d/%s/references", loader, os.Getenv("HOST"), projectId, modId), http.StatusOK, data.References)
-			_ = cache.Set(fmt.Sprintf("%s/%d/%s/references?ml=%s", os.Getenv("HOST"), projectId, modId, loader), http.StatusOK, data.References)
-			_ = cache.Set(fmt.Sprintf("forge.%s/%d/%s/references?ml=%s", os.Getenv("HOST"), projectId, modId, loader), http.StatusOK, data.References)
-			_ = cache.Set(fmt.Sprintf("%s.%s/%d/%s/references?ml=%s", loader, os.Getenv("HOST"), projectId, modId, loader), http.StatusOK, data.References)
+			_ = cache.Set(fmt.Sprintf("%s.%s/%d/%s", loader, host, projectId, modId), http.StatusOK, *data)
+			_ = cache.Set(fmt.Sprintf("%s/%d/%s?ml=%s", host, projectId, modId, loader), http.StatusOK, *data)
+			_ = cache.Set(fmt.Sprintf("forge.%s/%d/%s?ml=%s", host, projectId, modId, loader), http.StatusOK, *data)
+			_ = cache.Set(fmt.Sprintf("%s.%s/%d/%s?ml=%s", loader, host, projectId, modId, loader), http.StatusOK, *data)
+
+			_ = cache.Set(fmt.Sprintf("%s.%s/%d/%s/references", loader, host, projectId, modId), http.StatusOK, data.References)
+			_ = cache.Set(fmt.Sprintf("%s/%d/%s/references?ml=%s", host, projectId, modId, loader), http.StatusOK, data.References)
+			_ = cache.Set(fmt.Sprintf("forge.%s/%d/%s/references?ml=%s", host, projectId, modId, loader), http.StatusOK, data.References)
+			_ = cache.Set(fmt.Sprintf("%s.%s/%d/%s/references?ml=%s", loader, host, projectId, modId, loader), http.StatusOK, data.References)
 		}
 	}
 
+	if cfg.Reparse.Interval > 0 {
+		startReparseJob(time.Duration(cfg.Reparse.Interval), cfg.Reparse.Batch)
+	}
+
 	webLogger.Printf("Starting web services\n")
 	err = r.Run()
 	if err != nil {
@@ -125,11 +159,12 @@ func processRequest(c *gin.Context) {
 		return
 	}
 
+	opts := getUpdateOptions(c)
 	var data *models.UpdateJson
-	data, err = getUpdateJson(projectId, modId, loader, c.Request.Context())
+	data, err = getUpdateJson(projectId, modId, loader, opts, c.Request.Context())
 	cacheKey := cache.GetKey(c)
 
-	if errors.Is(err, curseforge.ErrInvalidProjectId) || errors.Is(err, curseforge.ErrUnsupportedGame) {
+	if isBadRequest(err) {
 		d := map[string]string{"error": err.Error()}
 		cache.Set(cacheKey, http.StatusBadRequest, d)
 		c.JSON(http.StatusBadRequest, d)
@@ -140,9 +175,10 @@ func processRequest(c *gin.Context) {
 		cache.AddHeaders(c, cacheExpireTime)
 		c.Status(http.StatusInternalServerError)
 	} else if data != nil {
-		cacheExpireTime := cache.Set(cacheKey, http.StatusOK, *data)
+		response := promosResponse(data, opts)
+		cacheExpireTime := cache.Set(cacheKey, http.StatusOK, response)
 		cache.AddHeaders(c, cacheExpireTime)
-		c.JSON(http.StatusOK, data)
+		c.JSON(http.StatusOK, response)
 	} else {
 		cacheExpireTime := cache.Set(cacheKey, http.StatusNotFound, nil)
 		cache.AddHeaders(c, cacheExpireTime)
@@ -150,9 +186,22 @@ func processRequest(c *gin.Context) {
 	}
 }
 
+// requireToken only lets through requests with the token as their bearer token
+func requireToken(token string) gin.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
 func expireCache(c *gin.Context) {
-	basePath := cache.GetKey(c)
-	basePath = strings.TrimSuffix(basePath, "/expire")
+	//the cache key includes the query, which is added back to each path below
+	path := strings.TrimSuffix(c.Request.URL.EscapedPath(), "/expire")
+	basePath := c.Request.Host + path
 
 	key := basePath
 	if c.Request.URL.RawQuery != "" {
@@ -166,9 +215,27 @@ func expireCache(c *gin.Context) {
 	}
 	cache.Remove(key)
 
+	//every loader is in the combined responses, so they go whichever loader is expired, under any options. They are
+	//asked for on the root host, but nothing stops them being asked for on a loader subdomain as well.
+	//the same goes for every mod of the project
+	projectPath := path[:strings.LastIndex(path, "/")]
+	for _, h := range getCacheHosts(c) {
+		cache.RemoveWithQuery(h + path + "/all")
+		cache.RemoveWithQuery(h + projectPath)
+	}
+
 	c.Status(http.StatusAccepted)
 }
 
+// getCacheHosts returns the hosts responses for the request may be cached under, which is the root host and the one
+// asked for if that is a loader subdomain
+func getCacheHosts(c *gin.Context) []string {
+	if host == "" || c.Request.Host == host {
+		return []string{c.Request.Host}
+	}
+	return []string{host, c.Request.Host}
+}
+
 func getReferences(c *gin.Context) {
 	pid := c.Param("projectId")
 	modId := c.Param("modId")
@@ -185,9 +252,9 @@ func getReferences(c *gin.Context) {
 	}
 
 	var data *models.UpdateJson
-	data, err = getUpdateJson(projectId, modId, loader, c.Request.Context())
+	data, err = getUpdateJson(projectId, modId, loader, getUpdateOptions(c), c.Request.Context())
 
-	if errors.Is(err, curseforge.ErrInvalidProjectId) || errors.Is(err, curseforge.ErrUnsupportedGame) {
+	if isBadRequest(err) {
 		d := map[string]string{"error": err.Error()}
 		cache.Set(cacheKey, http.StatusOK, d)
 		c.JSON(http.StatusBadRequest, d)
@@ -208,40 +275,75 @@ func getReferences(c *gin.Context) {
 	}
 }
 
-func getUpdateJson(projectId uint, modId string, loader string, ctx context.Context) (*models.UpdateJson, error) {
-	project, err := curseforge.GetProject(projectId, ctx)
-	if err != nil && !errors.Is(err, curseforge.ErrUnauthorized) {
+// promosResponse is what is sent to clients asking for the promos
+func promosResponse(data *models.UpdateJson, opts UpdateOptions) any {
+	if opts.Format == formatExtended {
+		return newExtendedUpdateJson(data)
+	}
+	return *data
+}
+
+// referencesResponse is what is sent to clients asking for the file of each promo
+func referencesResponse(data *models.UpdateJson, _ UpdateOptions) any {
+	return data.References
+}
+
+func getUpdateJson(projectId uint, modId string, loader string, opts UpdateOptions, ctx context.Context) (*models.UpdateJson, error) {
+	if err := opts.validate(); err != nil {
 		return nil, err
 	}
 
-	if project.GameId != 432 {
-		return nil, curseforge.ErrUnsupportedGame
+	project, versionMap, _, err := getProjectVersions(projectId, ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	versionMap := make(map[uint]*models.Version)
+	promos := getPromos(project, versionMap, modId, loader, opts, ctx)
 
-	var curseforgeFiles []curseforge.File
-	curseforgeFiles, err = curseforge.GetFilesForProject(project.Id, ctx)
+	//only what clients see by default is worth keeping a history of
+	if opts.isDefault() {
+		if err = recordSnapshot(projectId, modId, loader, promos.Promos, ctx); err != nil {
+			logger.Printf(ctx, "Failed to record promos: %s", err.Error())
+		}
+	}
+
+	return promos, nil
+}
+
+// getProjectVersions loads every mod of every file of the project, along with the errors of files that failed to load.
+// If CurseForge won't list the files, what we have stored is used instead.
+func getProjectVersions(projectId uint, ctx context.Context) (curseforge.Project, map[VersionKey]*models.Version, map[uint]error, error) {
+	project, versionMap, fileErrors, err := fetchProjectVersions(projectId, ctx)
 	if errors.Is(err, curseforge.ErrUnauthorized) {
 		//use our DB to pull what we know
-		var db *gorm.DB
-		db, err = database.Get(ctx)
+		versionMap, err = getStoredVersions(project.Id, ctx)
 		if err != nil {
-			return nil, err
+			return project, nil, nil, err
 		}
+		return project, versionMap, make(map[uint]error), nil
+	}
+	return project, versionMap, fileErrors, err
+}
 
-		var versions []*models.Version
+// fetchProjectVersions loads every mod of every file CurseForge lists for the project. ErrUnauthorized is returned,
+// along with the project, when CurseForge won't list them.
+func fetchProjectVersions(projectId uint, ctx context.Context) (curseforge.Project, map[VersionKey]*models.Version, map[uint]error, error) {
+	project, err := curseforge.GetProject(projectId, ctx)
+	if err != nil && !errors.Is(err, curseforge.ErrUnauthorized) {
+		return project, nil, nil, err
+	}
 
-		err = db.Where(&models.Version{CurseId: project.Id}).Find(&versions).Error
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
-		}
+	if project.GameId != 432 {
+		return project, nil, nil, curseforge.ErrUnsupportedGame
+	}
 
-		for _, v := range versions {
-			versionMap[v.FileId] = v
-		}
-	} else if err != nil {
-		return nil, err
+	versionMap := make(map[VersionKey]*models.Version)
+	fileErrors := make(map[uint]error)
+
+	var curseforgeFiles []curseforge.File
+	curseforgeFiles, err = curseforge.GetFilesForProject(project.Id, ctx)
+	if err != nil {
+		return project, nil, nil, err
 	}
 
 	var wg sync.WaitGroup
@@ -253,191 +355,383 @@ func getUpdateJson(projectId uint, modId string, loader string, ctx context.Cont
 			Wg:         &wg,
 			Mutex:      &writer,
 			VersionMap: versionMap,
+			Errors:     fileErrors,
 			Ctx:        ctx,
 			Project:    project,
-			ModId:      modId,
 		}
 	}
 	wg.Wait()
 
-	results := make(map[string]*models.Version)
+	return project, versionMap, fileErrors, nil
+}
+
+// getPromos picks the newest version of the mod for each MC version out of everything we know about the project
+func getPromos(project curseforge.Project, versionMap map[VersionKey]*models.Version, modId string, loader string, opts UpdateOptions, ctx context.Context) *models.UpdateJson {
+	return tracePromos(project, versionMap, modId, loader, opts, nil, ctx)
+}
+
+// tracePromos does the work of getPromos, recording why each version was or wasn't used when a trace is given
+func tracePromos(project curseforge.Project, versionMap map[VersionKey]*models.Version, modId string, loader string, opts UpdateOptions, trace *promoTrace, ctx context.Context) *models.UpdateJson {
+	return buildPromos(project, versionMap, modId, []string{loader}, opts, trace, ctx)[loader]
+}
+
+// buildPromos picks the promos for each of the loaders in a single pass over the versions. A trace should only be
+// given for a single loader.
+func buildPromos(project curseforge.Project, versionMap map[VersionKey]*models.Version, modId string, loaders []string, opts UpdateOptions, trace *promoTrace, ctx context.Context) map[string]*models.UpdateJson {
+	now := time.Now()
+	if !opts.At.IsZero() {
+		now = opts.At
+	}
+
+	var knownVersions []string
+	expandRanges := shouldExpandRanges(project.Id, opts)
+	if expandRanges && opts.At.IsZero() {
+		knownVersions = getKnownMinecraftVersions(versionMap, ctx)
+	} else if expandRanges {
+		knownVersions = getProjectMinecraftVersions(versionMap)
+	}
+
+	policy := promotion.Get(project.Id)
+	channel, _ := parseChannel(opts.Channel)
+	var channelKeys []int8
+	if opts.ChannelKeys {
+		channelKeys = getChannelKeys(channel)
+	}
+	promoters := make(map[string]*promoter, len(loaders))
+	for _, loader := range loaders {
+		promoters[loader] = newPromoter(policy, now, trace)
+		promoters[loader].channelKeys = channelKeys
+		promoters[loader].channel = channel
+	}
+	mismatches := make(map[string][]models.RangeMismatch)
 
 	for _, v := range versionMap {
-		if v.ModId == modId && v.Version != "" && slices.Contains(strings.Split(strings.ToLower(v.Loader), ","), strings.ToLower(loader)) {
-			gameVersions := strings.SplitSeq(v.GameVersions, ",")
-			for version := range gameVersions {
-				if invalidGameVersionRegex.MatchString(version) {
-					continue
-				}
-				key := version + "-latest"
-				existing, exists := results[key]
-				if !exists {
-					results[key] = v
-				} else if v.ReleaseDate.After(existing.ReleaseDate) {
-					results[key] = v
+		var gameVersions []string
+		var mismatch *models.RangeMismatch
+
+		for _, loader := range loaders {
+			if reason := getSkipReason(v, modId, loader); reason != "" {
+				trace.skip(v, reason)
+				continue
+			}
+			if !inChannel(v, channel) {
+				trace.skip(v, fmt.Sprintf("release type %s is not in the %s channel", getReleaseTypeName(v.Type), getReleaseTypeName(channel)))
+				continue
+			}
+
+			//the game versions are the same for every loader, so they are only worked out once
+			if gameVersions == nil {
+				gameVersions = getGameVersions(v, knownVersions)
+				if expandRanges {
+					mismatch = getRangeMismatch(v, knownVersions)
 				}
+			}
+			if mismatch != nil {
+				mismatches[loader] = append(mismatches[loader], *mismatch)
+			}
 
-				if v.Type == 1 {
-					key = version + "-recommended"
-					existing, exists = results[key]
-					if !exists {
-						results[key] = v
-					} else if v.ReleaseDate.After(existing.ReleaseDate) {
-						results[key] = v
-					}
+			for _, version := range gameVersions {
+				if invalidGameVersionRegex.MatchString(version) {
+					trace.drop(v, version)
+					continue
 				}
+				promoters[loader].add(v, version)
 			}
 		}
 	}
 
-	promos := &models.UpdateJson{
-		Promos:     map[string]string{},
-		References: map[string]string{},
-		HomePage:   project.Links.WebsiteUrl,
-	}
+	result := make(map[string]*models.UpdateJson, len(loaders))
+	for _, loader := range loaders {
+		results := promoters[loader].finish(versionMap, modId, loader)
 
-	for k, v := range results {
-		version, exists := versionMap[v.FileId]
-		if !exists || version == nil {
-			continue
+		promos := &models.UpdateJson{
+			Promos:     map[string]string{},
+			References: map[string]string{},
+			Versions:   map[string]*models.Version{},
+			HomePage:   project.Links.WebsiteUrl,
 		}
-		if version.ModId == modId && version.Version != "" {
-			promos.Promos[k] = version.Version
-			promos.References[k] = version.Url
+
+		for k, v := range results {
+			version, exists := versionMap[VersionKey{FileId: v.FileId, ModId: v.ModId}]
+			if !exists || version == nil {
+				continue
+			}
+			if matchesModId(version, modId) && version.Version != "" {
+				promos.Promos[k] = version.Version
+				promos.References[k] = version.Url
+				promos.Versions[k] = version
+			}
+		}
+
+		if expandRanges {
+			slices.SortFunc(mismatches[loader], func(a, b models.RangeMismatch) int { return cmp.Compare(a.FileId, b.FileId) })
+			promos.Diagnostics = &models.Diagnostics{RangeMismatches: mismatches[loader]}
+			if promos.Diagnostics.RangeMismatches == nil {
+				promos.Diagnostics.RangeMismatches = make([]models.RangeMismatch, 0)
+			}
+		}
+
+		//if CurseForge did not give us the project link, use what the mod says its page is
+		if promos.HomePage == "" {
+			if newest := getNewestVersion(promos.Versions, func(v *models.Version) bool { return v.DisplayUrl != "" }); newest != nil {
+				promos.HomePage = newest.DisplayUrl
+			}
 		}
+
+		result[loader] = promos
 	}
 
-	return promos, nil
+	return result
 }
 
-func getModVersion(project curseforge.Project, curseFile curseforge.File, modId string, ctx context.Context) (*models.Version, error) {
+func getModVersions(project curseforge.Project, curseFile curseforge.File, ctx context.Context) ([]*models.Version, error) {
 	db, err := database.Get(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	version := &models.Version{
-		CurseId: project.Id,
-		FileId:  curseFile.Id,
-	}
-
-	err = db.Where(version).Find(&version).Error
+	var versions []*models.Version
+	err = db.Where(&models.Version{CurseId: project.Id, FileId: curseFile.Id}).Find(&versions).Error
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return version, err
+		return nil, err
 	}
 
-	if errors.Is(err, gorm.ErrRecordNotFound) || version.Id == 0 {
-		reader, size, err := downloadFile(curseFile.DownloadUrl, ctx)
-		defer util.Close(reader)
+	if len(versions) == 0 {
+		failure, err := getFileFailure(db, curseFile.Id)
 		if err != nil {
-			return version, err
+			return nil, err
+		}
+		if failure != nil && !failure.CanRetry(time.Now()) {
+			metricRetriesSkipped.Add(1)
+			return nil, fmt.Errorf("%w: %s", ErrRetryLater, failure.Message)
 		}
 
-		r, err := zip.NewReader(reader, size)
+		versions, err = indexFile(db, project, curseFile, ctx)
 		if err != nil {
-			return version, err
+			return nil, recordFileFailure(db, project, curseFile, failure, err, ctx)
+		}
+		if failure != nil {
+			err = db.Delete(failure).Error
 		}
+		return versions, err
+	}
 
-		var manifestVersion string
-		manifestVersion, _ = getManifestVersion(r)
+	if isOutdated(versions) && lazyReparses.Allow(time.Now()) {
+		reparsed, err := tryReparse(db, project, curseFile, versions, ctx)
+		if err == nil {
+			return reparsed, nil
+		}
+		logger.Printf(ctx, "Failed to reparse file %d, using existing data: %s", curseFile.Id, err.Error())
+	}
 
-		var modInfo *models.ModInfo
-		modInfo = parseJarFile(r, ctx)
+	//tags, release types and downloads can be changed on CurseForge after upload, and apply to every mod in the file
+	gameVersions := strings.Join(curseFile.GameVersions, ",")
+	for _, version := range versions {
+		changed := false
+		if !util.AreEqual(strings.Split(version.GameVersions, ","), curseFile.GameVersions) {
+			version.GameVersions = gameVersions
+			changed = true
+		}
 
-		//update info if manifest has a version
-		if modInfo != nil && manifestVersion != "" {
-			for k, v := range modInfo.Mods {
-				if v.Version == "${file.jarVersion}" {
-					modInfo.Mods[k] = models.Mod{
-						ModId:   v.ModId,
-						Version: manifestVersion,
-					}
-				}
-			}
+		if version.Type != curseFile.ReleaseType {
+			version.Type = curseFile.ReleaseType
+			changed = true
 		}
 
-		if modInfo != nil {
-			switch modInfo.ModLoader {
-			case "forge":
-				if slices.Contains(curseFile.GameVersions, "NeoForge") {
-					modInfo.ModLoader = "forge,neoforge"
-				}
-			case "fabric":
-				if slices.Contains(curseFile.GameVersions, "Quilt") {
-					modInfo.ModLoader = "fabric,quilt"
-				}
-			}
+		if version.FileName != curseFile.FileName || version.DownloadUrl != curseFile.DownloadUrl {
+			version.FileName = curseFile.FileName
+			version.DownloadUrl = curseFile.DownloadUrl
+			changed = true
 		}
 
-		version.ReleaseDate = curseFile.FileDate
-		version.Type = curseFile.ReleaseType
-
-		if modInfo != nil && len(modInfo.Mods) > 0 {
-			var matchingVersion *models.Version
-			for _, z := range modInfo.Mods {
-				version.Id = 0 //resets the id so we can create a new row for this mod id
-				version.Version = z.Version
-				version.ModId = z.ModId
-				version.Url = fmt.Sprintf("%s/files/%d", project.Links.WebsiteUrl, curseFile.Id)
-				version.GameVersions = strings.Join(curseFile.GameVersions, ",")
-				version.Loader = modInfo.ModLoader
-				err = db.Create(version).Error
-				if err != nil {
-					return version, err
-				}
-				if version.ModId == modId {
-					matchingVersion = version
-				}
+		if changed {
+			err = db.Save(version).Error
+			if err != nil {
+				return versions, err
 			}
-
-			return matchingVersion, err
 		}
+	}
 
-		//create with no real data, because it doesn't exist
-		version.Url = fmt.Sprintf("%s/files/%d", project.Links.WebsiteUrl, curseFile.Id)
-		version.GameVersions = strings.Join(curseFile.GameVersions, ",")
+	return versions, err
+}
 
-		err = db.Create(version).Error
-		return version, err
+// indexFile downloads a file we have not seen before and stores a row for each mod in it
+func indexFile(db *gorm.DB, project curseforge.Project, curseFile curseforge.File, ctx context.Context) ([]*models.Version, error) {
+	modInfo, err := readFile(project, curseFile, ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	currentVersions := strings.Split(version.GameVersions, ",")
-	if !util.AreEqual(currentVersions, curseFile.GameVersions) {
-		version.GameVersions = strings.Join(curseFile.GameVersions, ",")
-		err = db.Save(version).Error
+	return saveVersions(db, project, curseFile, modInfo, ctx)
+}
+
+// readFile downloads a file and reads the mods in it. Breaking the archive limits is returned as ErrArchiveLimit.
+func readFile(project curseforge.Project, curseFile curseforge.File, ctx context.Context) (*models.ModInfo, error) {
+	reader, size, closer, err := openFile(curseFile, ctx)
+	defer util.Close(closer)
+	if err != nil {
+		return nil, err
 	}
 
-	if version.Type != curseFile.ReleaseType {
-		version.Type = curseFile.ReleaseType
-		err = db.Save(version).Error
+	r, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, err
 	}
 
-	return version, err
+	var manifestVersion string
+	var modInfo *models.ModInfo
+	if project.IsPack() {
+		modInfo, err = parsePackFile(r, project, curseFile, ctx)
+	} else {
+		manifestVersion, _ = getManifestVersion(r)
+		modInfo, err = parseJarFile(r, ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	applyManifestVersion(modInfo, manifestVersion)
+
+	if modInfo != nil {
+		switch modInfo.ModLoader {
+		case "forge":
+			if slices.Contains(curseFile.GameVersions, "NeoForge") {
+				modInfo.ModLoader = "forge,neoforge"
+			}
+		case "fabric":
+			if slices.Contains(curseFile.GameVersions, "Quilt") {
+				modInfo.ModLoader = "fabric,quilt"
+			}
+		}
+	}
+
+	return modInfo, nil
 }
 
-func parseJarFile(file *zip.Reader, ctx context.Context) *models.ModInfo {
+// saveVersions stores a row for each mod of the file. A file with no mods still gets a placeholder row so it is not
+// downloaded again.
+func saveVersions(db *gorm.DB, project curseforge.Project, curseFile curseforge.File, modInfo *models.ModInfo, ctx context.Context) ([]*models.Version, error) {
+	versions := make([]*models.Version, 0)
+	if modInfo != nil && len(modInfo.Mods) > 0 {
+		for _, z := range modInfo.Mods {
+			//each mod id in the jar gets its own row
+			version := newVersion(project, curseFile)
+			version.Version = z.Version
+			version.ModId = z.ModId
+			version.Loader = modInfo.ModLoader
+			version.PackFormat = z.PackFormat
+			version.DisplayName = z.DisplayName
+			version.Description = z.Description
+			version.Authors = z.Authors
+			version.License = z.License
+			version.LogoFile = z.LogoFile
+			version.DisplayUrl = z.DisplayURL
+			version.IssueTrackerUrl = z.IssueTrackerURL
+			version.Side = z.Side
+			version.McVersionRange = getMinecraftRange(modInfo.Dependencies[z.ModId])
+			version.Provides = z.Provides
+			err := db.Create(version).Error
+			if err != nil {
+				return versions, err
+			}
+			err = saveDependencies(db, version.Id, modInfo.Dependencies[z.ModId])
+			if err != nil {
+				return versions, err
+			}
+			versions = append(versions, version)
+		}
+
+		return versions, nil
+	}
+
+	//create with no real data, because it doesn't exist
+	version := newVersion(project, curseFile)
+	err := db.Create(version).Error
+	return []*models.Version{version}, err
+}
+
+func newVersion(project curseforge.Project, curseFile curseforge.File) *models.Version {
+	return &models.Version{
+		CurseId:       project.Id,
+		FileId:        curseFile.Id,
+		GameVersions:  strings.Join(curseFile.GameVersions, ","),
+		Type:          curseFile.ReleaseType,
+		ReleaseDate:   curseFile.FileDate,
+		Url:           fmt.Sprintf("%s/files/%d", project.Links.WebsiteUrl, curseFile.Id),
+		FileName:      curseFile.FileName,
+		DownloadUrl:   curseFile.DownloadUrl,
+		ParserVersion: parserVersion,
+	}
+}
+
+// parseJarFile reads the mods out of every metadata file in the jar. Entries that fail to parse are skipped, the only
+// error returned is an archive that breaks the limits, since nothing more should be read from it
+func parseJarFile(file *zip.Reader, ctx context.Context) (*models.ModInfo, error) {
+	result, entryErrors, err := inspectJarFile(file, ctx)
+	for _, v := range entryErrors {
+		logger.Printf(ctx, "Failed to parse mod file %s: %s", v.Entry, v.Error)
+	}
+	return result, err
+}
+
+// inspectJarFile does the work of parseJarFile, returning the errors of each entry that failed to parse
+func inspectJarFile(file *zip.Reader, ctx context.Context) (*models.ModInfo, []models.EntryError, error) {
+	if err := checkArchive(file); err != nil {
+		return nil, nil, err
+	}
+
 	var result *models.ModInfo
+	entryErrors := make([]models.EntryError, 0)
 	for _, f := range file.File {
 		info, err := checkZipFile(f, ctx)
-		if err != nil {
-			logger.Printf(ctx, "Failed to parse mod file %s: %s", f.Name, err)
+		if errors.Is(err, ErrArchiveLimit) {
+			return nil, entryErrors, err
+		} else if err != nil {
+			entryErrors = append(entryErrors, models.EntryError{Entry: f.Name, Error: err.Error()})
 		} else if info != nil {
 			if result == nil {
 				result = info
 			} else {
 				result.Mods = append(result.Mods, info.Mods...)
 				result.ModLoader = result.ModLoader + "," + info.ModLoader
+				if result.Dependencies == nil {
+					result.Dependencies = make(map[string][]models.Dependency)
+				}
+				for k, v := range info.Dependencies {
+					if _, exists := result.Dependencies[k]; !exists {
+						result.Dependencies[k] = v
+					}
+				}
 			}
 		}
 	}
 
+	//very old mods may not have any metadata file, so the only place the mod id lives is the @Mod annotation
+	if result == nil {
+		var classErrors []models.EntryError
+		var err error
+		result, classErrors, err = scanModAnnotations(file)
+		entryErrors = append(entryErrors, classErrors...)
+		if err != nil {
+			return nil, entryErrors, err
+		}
+	}
+
 	if result != nil {
 		existingLoaders := strings.Split(result.ModLoader, ",")
 		result.ModLoader = strings.Join(util.Dedup(existingLoaders), ",")
-		result.Mods = util.Dedup(result.Mods)
+
+		//a jar with both a fabric.mod.json and quilt.mod.json declares the same mod twice, only keep the first
+		mods := make([]models.Mod, 0, len(result.Mods))
+		for _, v := range result.Mods {
+			if !slices.ContainsFunc(mods, func(m models.Mod) bool { return m.ModId == v.ModId }) {
+				mods = append(mods, v)
+			}
+		}
+		result.Mods = mods
 	}
 
-	return result
+	return result, entryErrors, nil
 }
 
 func checkZipFile(file *zip.File, ctx context.Context) (*models.ModInfo, error) {
@@ -455,6 +749,8 @@ func checkZipFile(file *zip.File, ctx context.Context) (*models.ModInfo, error)
 		}
 		//reset what the info actually has for the loader, because we don't care about javafml
 		modInfo.ModLoader = ""
+		normalizeDependencies(modInfo.Dependencies)
+		applyTomlMetadata(modInfo)
 
 		//see if the deps tell us which one is needed, ignore the mod id though...
 		for _, v := range modInfo.Dependencies {
@@ -490,27 +786,48 @@ func checkZipFile(file *zip.File, ctx context.Context) (*models.ModInfo, error)
 		}
 		//reset what the info actually has for the loader, because we don't care about javafml
 		modInfo.ModLoader = "neoforge"
+		normalizeDependencies(modInfo.Dependencies)
+		applyTomlMetadata(modInfo)
 		return modInfo, nil
 	}
 
-	if file.Name == "fabric.mod.json" || file.Name == "quilt.mod.json" {
+	if file.Name == "fabric.mod.json" {
 		data, err := readZipEntry(file)
 		if err != nil {
 			return nil, err
 		}
 
-		var mod models.Mod
+		var mod models.FabricMod
 		err = json.Unmarshal(data, &mod)
 		if err != nil {
 			return nil, err
 		}
 
-		modInfo = &models.ModInfo{Mods: []models.Mod{mod}}
-		if file.Name == "fabric.mod.json" {
-			modInfo.ModLoader = "fabric"
+		modInfo = &models.ModInfo{
+			Mods:         []models.Mod{fabricMetadata(mod)},
+			ModLoader:    "fabric",
+			Dependencies: map[string][]models.Dependency{mod.ModId: fabricDependencies(mod)},
 		}
-		if file.Name == "quilt.mod.json" {
-			modInfo.ModLoader = "quilt"
+
+		return modInfo, nil
+	}
+
+	if file.Name == "quilt.mod.json" {
+		data, err := readZipEntry(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var mod models.QuiltMod
+		err = json.Unmarshal(data, &mod)
+		if err != nil {
+			return nil, err
+		}
+
+		modInfo = &models.ModInfo{
+			Mods:         []models.Mod{quiltMetadata(mod)},
+			ModLoader:    "quilt",
+			Dependencies: map[string][]models.Dependency{mod.QuiltLoader.Id: quiltDependencies(mod)},
 		}
 
 		return modInfo, nil
@@ -525,7 +842,7 @@ func checkZipFile(file *zip.File, ctx context.Context) (*models.ModInfo, error)
 		//there is 2 possible "variants" of the file that we can expect.
 		//one is the full array, another is an object of them
 		//check for the array first
-		var mods []models.Mod
+		var mods []models.LegacyMod
 		err = json.Unmarshal(data, &mods)
 
 		//if there is nothing in the array, assume second format
@@ -539,13 +856,19 @@ func checkZipFile(file *zip.File, ctx context.Context) (*models.ModInfo, error)
 			return modInfo, nil
 		}
 
-		modInfo = &models.ModInfo{Mods: mods, ModLoader: "forge"}
-
-		for k, v := range modInfo.Mods {
-			modInfo.Mods[k] = models.Mod{
-				ModId:   v.OldModId,
-				Version: v.Version,
-			}
+		modInfo = &models.ModInfo{ModLoader: "forge"}
+
+		for _, v := range mods {
+			modInfo.Mods = append(modInfo.Mods, models.Mod{
+				ModId:       v.OldModId,
+				Version:     v.Version,
+				DisplayName: v.DisplayName,
+				Description: strings.TrimSpace(v.Description),
+				Authors:     strings.Join(v.AuthorList, ", "),
+				LogoFile:    v.LogoFile,
+				DisplayURL:  v.DisplayURL,
+				Side:        "BOTH",
+			})
 		}
 
 		return modInfo, nil
@@ -554,6 +877,20 @@ func checkZipFile(file *zip.File, ctx context.Context) (*models.ModInfo, error)
 	return modInfo, nil
 }
 
+// applyManifestVersion fills in mods which take their version from the manifest
+func applyManifestVersion(modInfo *models.ModInfo, manifestVersion string) {
+	if modInfo == nil || manifestVersion == "" {
+		return
+	}
+
+	for k, v := range modInfo.Mods {
+		if v.Version == jarVersionPlaceholder {
+			v.Version = manifestVersion
+			modInfo.Mods[k] = v
+		}
+	}
+}
+
 func getManifestVersion(reader *zip.Reader) (string, error) {
 	for _, file := range reader.File {
 		if file.Name == "META-INF/MANIFEST.MF" {
@@ -579,12 +916,30 @@ func downloadFile(url string, ctx context.Context) (*util.TempFile, int64, error
 
 	defer response.Body.Close()
 
+	if response.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("%w: %s", errDownloadFailed, response.Status)
+	}
+
+	if response.ContentLength > limits.DownloadSize {
+		return nil, 0, fmt.Errorf("%w: download is %d bytes, limit is %d", ErrArchiveLimit, response.ContentLength, limits.DownloadSize)
+	}
+
 	f, err := util.NewTempFile()
-	size, err := io.Copy(f, response.Body)
 	if err != nil {
 		return nil, 0, err
 	}
 
+	//the content length is not always sent, so stop copying once past the limit
+	size, err := io.Copy(f, io.LimitReader(response.Body, limits.DownloadSize+1))
+	if err != nil {
+		util.Close(f)
+		return nil, 0, err
+	}
+	if size > limits.DownloadSize {
+		util.Close(f)
+		return nil, 0, fmt.Errorf("%w: download is over %d bytes", ErrArchiveLimit, limits.DownloadSize)
+	}
+
 	return f, size, nil
 }
 
@@ -604,13 +959,17 @@ func readFromCache(c *gin.Context) {
 }
 
 func readZipEntry(file *zip.File) ([]byte, error) {
+	if err := checkEntry(file); err != nil {
+		return nil, err
+	}
+
 	fileReader, err := file.Open()
 	if err != nil {
 		return nil, err
 	}
 	defer fileReader.Close()
 
-	return io.ReadAll(fileReader)
+	return io.ReadAll(io.LimitReader(fileReader, int64(limits.EntrySize)))
 }
 
 func readManifest(data []byte) map[string]string {
@@ -625,7 +984,66 @@ func readManifest(data []byte) map[string]string {
 	return parsed
 }
 
+// supportsLoader checks if the requested loader is one of the comma-separated loaders on a version. Packs do not
+// depend on a loader, so they are served for any of them.
+func supportsLoader(loaders string, loader string) bool {
+	loader = strings.ToLower(loader)
+	for _, v := range strings.Split(strings.ToLower(loaders), ",") {
+		if v == loader || isPackLoader(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateOptions are the optional behaviours a request may ask for when building the update JSON
+type UpdateOptions struct {
+	ExpandRanges bool
+	//only use files of this release type or a more stable one
+	Channel string
+	//add a key for each channel, such as 1.20.1-beta
+	ChannelKeys bool
+	//build the promos as they were at this time, using only what is stored
+	At time.Time
+	//how the promos are written out, which does not change what they are
+	Format string
+}
+
+func getUpdateOptions(c *gin.Context) UpdateOptions {
+	return UpdateOptions{
+		ExpandRanges: cast.ToBool(c.Query("expand")),
+		Channel:      c.Query("channel"),
+		ChannelKeys:  cast.ToBool(c.Query("channels")),
+		Format:       strings.ToLower(c.Query("format")),
+	}
+}
+
+// validate checks the options asked for by the client are ones we know
+func (o UpdateOptions) validate() error {
+	if _, err := parseChannel(o.Channel); err != nil {
+		return err
+	}
+	if o.Format != formatForge && o.Format != formatExtended {
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
+// isDefault checks if the options give the promos served when none are asked for
+func (o UpdateOptions) isDefault() bool {
+	o.Format = formatForge
+	return o == UpdateOptions{}
+}
+
 func getLoader(c *gin.Context) string {
+	if loader := getRequestedLoader(c); loader != "" {
+		return loader
+	}
+	return "forge"
+}
+
+// getRequestedLoader returns the loader asked for in the query or subdomain, or nothing if there isn't one
+func getRequestedLoader(c *gin.Context) string {
 	loader := c.Query("ml")
 	if loader != "" {
 		return strings.ToLower(loader)
@@ -635,7 +1053,7 @@ func getLoader(c *gin.Context) string {
 		return strings.ToLower(loader)
 	}
 
-	rootHost := os.Getenv("HOST")
+	rootHost := host
 	if rootHost != "" {
 		rootHost = "." + rootHost
 		host := c.Request.Host
@@ -644,5 +1062,5 @@ func getLoader(c *gin.Context) string {
 		}
 	}
 
-	return "forge"
+	return ""
 }
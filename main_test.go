@@ -2,13 +2,36 @@ package main
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/cfwidget/updatejson/cache"
+	"github.com/cfwidget/updatejson/config"
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/database"
 	"github.com/cfwidget/updatejson/models"
+	"github.com/cfwidget/updatejson/store"
 	"github.com/cfwidget/updatejson/util"
+	"github.com/gin-gonic/gin"
 	"github.com/pelletier/go-toml/v2"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/clause"
 )
 
 func Test_areEqual(t *testing.T) {
@@ -122,7 +145,10 @@ func Test_GetModLoaderFromJar(t *testing.T) {
 			}
 
 			var modInfo *models.ModInfo
-			modInfo = parseJarFile(r, ctx)
+			modInfo, err = parseJarFile(r, ctx)
+			if !assert.NoError(t, err, "error parsing file") {
+				return
+			}
 
 			if !assert.NotNil(t, modInfo, "error parsing file") {
 				return
@@ -141,6 +167,1391 @@ func Test_UnmarshalTOML(t *testing.T) {
 	if !assert.NoError(t, err, "error reading file") {
 		return
 	}
+
+	normalizeDependencies(modInfo.Dependencies)
+	deps := modInfo.Dependencies["examplemod"]
+	if !assert.Len(t, deps, 2) {
+		return
+	}
+	assert.Equal(t, models.Dependency{ModId: "forge", Mandatory: true, Type: "required", VersionRange: "[41,)", Ordering: "NONE", Side: "BOTH"}, deps[0])
+	assert.Equal(t, models.Dependency{ModId: "minecraft", Mandatory: true, Type: "required", VersionRange: "[1.19,1.20)", Ordering: "NONE", Side: "BOTH"}, deps[1])
+
+	applyTomlMetadata(modInfo)
+	if !assert.Len(t, modInfo.Mods, 1) {
+		return
+	}
+	mod := modInfo.Mods[0]
+	assert.Equal(t, "Example Mod", mod.DisplayName)
+	assert.Equal(t, "Author", mod.Authors)
+	assert.Equal(t, "All rights reserved", mod.License)
+	assert.Equal(t, "logo.png", mod.LogoFile)
+	assert.Equal(t, "minecraftforge.net", mod.DisplayURL)
+	assert.Equal(t, "github.com/MinecraftForge/MinecraftForge/issues", mod.IssueTrackerURL)
+	assert.Equal(t, "BOTH", mod.Side)
+	assert.True(t, strings.HasPrefix(mod.Description, "Lets you craft dirt into diamonds."))
+}
+
+func Test_FabricMetadata(t *testing.T) {
+	var mod models.FabricMod
+	err := json.Unmarshal([]byte(`{
+		"id": "examplemod",
+		"version": "1.0.0",
+		"name": "Example Mod",
+		"description": "Dirt into diamonds",
+		"authors": ["Me", {"name": "You", "contact": {"homepage": "https://example.com"}}],
+		"license": ["MIT", "CC0-1.0"],
+		"icon": {"16": "icon16.png", "128": "icon128.png"},
+		"contact": {"homepage": "https://example.com/mod", "issues": "https://example.com/issues"},
+		"environment": "client"
+	}`), &mod)
+	if !assert.NoError(t, err, "error reading file") {
+		return
+	}
+
+	assert.Equal(t, models.Mod{
+		ModId:           "examplemod",
+		Version:         "1.0.0",
+		DisplayName:     "Example Mod",
+		Description:     "Dirt into diamonds",
+		Authors:         "Me, You",
+		License:         "MIT, CC0-1.0",
+		LogoFile:        "icon128.png",
+		DisplayURL:      "https://example.com/mod",
+		IssueTrackerURL: "https://example.com/issues",
+		Side:            "CLIENT",
+	}, fabricMetadata(mod))
+}
+
+func Test_FabricDependencies(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"fabric.mod.json": `{"id": "examplemod", "version": "1.0.0", "depends": {"fabricloader": ">=0.14", "minecraft": ["1.20", "1.20.1"]}, "suggests": {"modmenu": "*"}}`,
+	})
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !assert.NoError(t, err, "error reading file") {
+		return
+	}
+
+	modInfo, err := parseJarFile(r, context.Background())
+	if !assert.NoError(t, err, "error parsing file") {
+		return
+	}
+	if !assert.NotNil(t, modInfo, "error parsing file") {
+		return
+	}
+
+	assert.Equal(t, []models.Dependency{
+		{ModId: "fabricloader", Mandatory: true, Type: "required", VersionRange: ">=0.14", Ordering: "NONE", Side: "BOTH"},
+		{ModId: "minecraft", Mandatory: true, Type: "required", VersionRange: "1.20 || 1.20.1", Ordering: "NONE", Side: "BOTH"},
+		{ModId: "modmenu", Mandatory: false, Type: "optional", VersionRange: "*", Ordering: "NONE", Side: "BOTH"},
+	}, modInfo.Dependencies["examplemod"])
+}
+
+func Test_Dependencies(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+
+	db, err := database.Get(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	versions := []*models.Version{
+		{CurseId: 1, FileId: 2, ModId: "examplemod", Version: "1.1.0", Loader: "forge", GameVersions: "1.20.1", Type: 2, Url: "https://example.com/files/2"},
+		{CurseId: 1, FileId: 1, ModId: "examplemod", Version: "1.0.0", Loader: "forge", GameVersions: "1.20.1", Type: 1, Url: "https://example.com/files/1"},
+	}
+	if !assert.NoError(t, db.Create(versions).Error) {
+		return
+	}
+	if !assert.NoError(t, saveDependencies(db, versions[0].Id, []models.Dependency{
+		{ModId: "forge", Type: "required", Mandatory: true, VersionRange: "[47,)", Side: "BOTH", Ordering: "NONE"},
+		{ModId: "jei", Type: "optional", VersionRange: "*", Side: "CLIENT", Ordering: "AFTER"},
+	})) {
+		return
+	}
+
+	data := &models.UpdateJson{Versions: map[string]*models.Version{
+		"1.20.1-latest":      versions[0],
+		"1.20.1-recommended": versions[1],
+	}}
+	deps, err := getPromoDependencies(data, "1.20.1", ctx)
+	if !assert.NoError(t, err) || !assert.Len(t, deps, 2) {
+		return
+	}
+	assert.Equal(t, "1.1.0", deps["latest"].Version)
+	assert.Equal(t, "https://example.com/files/2", deps["latest"].Url)
+	if assert.Len(t, deps["latest"].Dependencies, 2) {
+		assert.Equal(t, "forge", deps["latest"].Dependencies[0].ModId)
+		assert.Equal(t, "jei", deps["latest"].Dependencies[1].ModId)
+		assert.Equal(t, "CLIENT", deps["latest"].Dependencies[1].Side)
+	}
+	assert.Equal(t, "1.0.0", deps["recommended"].Version)
+	assert.Empty(t, deps["recommended"].Dependencies)
+
+	//other Minecraft versions have nothing
+	deps, err = getPromoDependencies(data, "1.19.2", ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, deps)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/:projectId/:modId/dependencies", readFromCache, getDependencies)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/1/examplemod/dependencies", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.JSONEq(t, `{"error":"mc version is required"}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/examplemod/examplemod/dependencies?mc=1.20.1", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func Test_ModMetadata(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+
+	db, err := database.Get(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	now := time.Now()
+	versions := []*models.Version{
+		{CurseId: 1, FileId: 2, ModId: "examplemod", Version: "1.1.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now, Type: 1,
+			DisplayName: "Example Mod", Description: "An example", Authors: "Me, You", License: "MIT", LogoFile: "logo.png",
+			DisplayUrl: "https://example.com/mod", IssueTrackerUrl: "https://example.com/issues", Side: "BOTH", Url: "https://example.com/files/2"},
+		{CurseId: 1, FileId: 1, ModId: "examplemod", Version: "1.0.0", Loader: "forge", GameVersions: "1.19.2", ReleaseDate: now.Add(-time.Hour), Type: 1},
+	}
+	if !assert.NoError(t, db.Create(versions).Error) {
+		return
+	}
+
+	//CurseForge refusing to list the files means what is stored gets used
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	baseUrl := curseforge.BaseUrl
+	curseforge.BaseUrl = server.URL + "/"
+	t.Cleanup(func() { curseforge.BaseUrl = baseUrl })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/:projectId/:modId/info", readFromCache, getModMetadata)
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/1/examplemod/info?ml=forge", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{
+		"modId": "examplemod",
+		"version": "1.1.0",
+		"displayName": "Example Mod",
+		"description": "An example",
+		"authors": "Me, You",
+		"license": "MIT",
+		"logoFile": "logo.png",
+		"displayUrl": "https://example.com/mod",
+		"issueTrackerUrl": "https://example.com/issues",
+		"side": "BOTH",
+		"url": "https://example.com/files/2"
+	}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/1/examplemod/info?ml=forge&mc=1.19.2", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"version":"1.0.0"`)
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/1/othermod/info?ml=forge", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func Test_ParsePackFile(t *testing.T) {
+	inner := buildZip(t, map[string]string{
+		"pack.mcmeta": `{"pack": {"pack_format": 15, "description": {"text": "Better ", "extra": ["Leaves"]}}}`,
+	})
+	tests := []struct {
+		Name    string
+		Files   map[string]string
+		ClassId int
+		Loader  string
+	}{
+		{
+			Name:    "resourcepack",
+			Files:   map[string]string{"pack.mcmeta": `{"pack": {"pack_format": 15, "description": "Better Leaves"}}`},
+			ClassId: curseforge.ClassResourcePacks,
+			Loader:  resourcePackLoader,
+		},
+		{
+			Name:    "datapack-nested",
+			Files:   map[string]string{"betterleaves.zip": string(inner)},
+			ClassId: curseforge.ClassDataPacks,
+			Loader:  dataPackLoader,
+		},
+	}
+	for _, v := range tests {
+		t.Run(v.Name, func(t *testing.T) {
+			data := buildZip(t, v.Files)
+			r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			if !assert.NoError(t, err, "error reading file") {
+				return
+			}
+
+			project := curseforge.Project{Id: 1, ClassId: v.ClassId, Slug: "better-leaves"}
+			file := curseforge.File{DisplayName: "Better Leaves v9.1.2 [1.20.1]", FileName: "BetterLeaves-9.1.2.zip"}
+			modInfo, err := parsePackFile(r, project, file, context.Background())
+			if !assert.NoError(t, err, "error parsing file") {
+				return
+			}
+			if !assert.NotNil(t, modInfo, "error parsing file") || !assert.Len(t, modInfo.Mods, 1) {
+				return
+			}
+
+			assert.Equal(t, v.Loader, modInfo.ModLoader)
+			assert.Equal(t, "better-leaves", modInfo.Mods[0].ModId)
+			assert.Equal(t, "9.1.2", modInfo.Mods[0].Version)
+			assert.Equal(t, "Better Leaves", modInfo.Mods[0].Description)
+			assert.Equal(t, 15, modInfo.Mods[0].PackFormat)
+		})
+	}
+}
+
+func Test_QuiltMod(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"quilt.mod.json":  testQuiltJSON,
+		"fabric.mod.json": `{"id": "examplemod", "version": "2.0.0", "provides": ["example_api"]}`,
+	})
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !assert.NoError(t, err, "error reading file") {
+		return
+	}
+
+	modInfo, err := parseJarFile(r, context.Background())
+	if !assert.NoError(t, err, "error parsing file") {
+		return
+	}
+	if !assert.NotNil(t, modInfo, "error parsing file") || !assert.Len(t, modInfo.Mods, 1) {
+		return
+	}
+
+	assert.ElementsMatch(t, []string{"fabric", "quilt"}, strings.Split(modInfo.ModLoader, ","))
+	assert.Equal(t, "examplemod", modInfo.Mods[0].ModId)
+	assert.Equal(t, "2.0.0", modInfo.Mods[0].Version)
+
+	var quilt models.QuiltMod
+	err = json.Unmarshal([]byte(testQuiltJSON), &quilt)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mod := quiltMetadata(quilt)
+	assert.Equal(t, "examplemod", mod.ModId)
+	assert.Equal(t, "2.0.0", mod.Version)
+	assert.Equal(t, "Example Mod", mod.DisplayName)
+	assert.Equal(t, "Me, You", mod.Authors)
+	assert.Equal(t, "MIT, Custom License", mod.License)
+	assert.Equal(t, "example_api,example-legacy", mod.Provides)
+	assert.Equal(t, "CLIENT", mod.Side)
+
+	assert.Equal(t, []models.Dependency{
+		{ModId: "quilt_loader", Mandatory: true, Type: "required", Ordering: "NONE", Side: "BOTH"},
+		{ModId: "minecraft", Mandatory: true, Type: "required", VersionRange: ">=1.20 <1.20.2", Ordering: "NONE", Side: "BOTH"},
+		{ModId: "modmenu", Mandatory: false, Type: "optional", VersionRange: "1.0 || 2.0", Ordering: "NONE", Side: "BOTH"},
+		{ModId: "fabric-api", Mandatory: false, Type: "optional", Ordering: "NONE", Side: "BOTH"},
+		{ModId: "qsl", Mandatory: false, Type: "optional", VersionRange: ">=6.0", Ordering: "NONE", Side: "BOTH"},
+		{ModId: "oldmod", Type: "incompatible", Ordering: "NONE", Side: "BOTH"},
+	}, quiltDependencies(quilt))
+
+	version := &models.Version{FileId: 1, ModId: mod.ModId, Version: mod.Version, Provides: mod.Provides, Loader: "quilt", GameVersions: "1.20.1"}
+	versionMap := newVersionMap(version)
+	for _, modId := range []string{"examplemod", "example_api", "example-legacy"} {
+		promos := getPromos(curseforge.Project{}, versionMap, modId, "quilt", UpdateOptions{}, context.Background())
+		assert.Equal(t, "2.0.0", promos.Promos["1.20.1-latest"], modId)
+	}
+}
+
+func Test_ExpandRanges(t *testing.T) {
+	known := []string{"1.19.4", "1.20", "1.20.1", "1.20.2"}
+	version := &models.Version{
+		FileId:         1,
+		Version:        "1.0.0",
+		GameVersions:   "1.19.4,1.20.1,Forge",
+		Loader:         "forge",
+		McVersionRange: "[1.20,1.20.2)",
+	}
+
+	assert.Equal(t, []string{"1.19.4", "1.20.1", "Forge"}, getGameVersions(version, nil))
+	assert.Equal(t, []string{"1.19.4", "1.20.1", "Forge", "1.20"}, getGameVersions(version, known))
+	assert.Equal(t, &models.RangeMismatch{
+		FileId:        1,
+		Version:       "1.0.0",
+		DeclaredRange: "[1.20,1.20.2)",
+		Untagged:      []string{"1.20"},
+		OutOfRange:    []string{"1.19.4"},
+	}, getRangeMismatch(version, known))
+
+	version.GameVersions = "1.20,1.20.1"
+	assert.Nil(t, getRangeMismatch(version, known))
+
+	//soft, wildcard and open ended ranges would cover every version, so they aren't expanded
+	for _, tt := range []struct{ loader, spec string }{{"forge", "1.20.1"}, {"forge", "[1.20,)"}, {"fabric", "*"}, {"fabric", ">=1.20"}} {
+		version.Loader = tt.loader
+		version.McVersionRange = tt.spec
+		assert.Equal(t, []string{"1.20", "1.20.1"}, getGameVersions(version, known), tt.spec)
+		assert.Nil(t, getRangeMismatch(version, known), tt.spec)
+	}
+
+	//but a file tagged for a version before an open range still doesn't match it
+	version.Loader = "forge"
+	version.McVersionRange = "[1.20,)"
+	version.GameVersions = "1.19.4,1.20.1"
+	assert.Equal(t, &models.RangeMismatch{
+		FileId:        1,
+		Version:       "1.0.0",
+		DeclaredRange: "[1.20,)",
+		OutOfRange:    []string{"1.19.4"},
+	}, getRangeMismatch(version, known))
+}
+
+func Test_MultipleModIds(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+
+	jar := buildZip(t, map[string]string{"META-INF/mods.toml": testMultiModTOML})
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		_, _ = w.Write(jar)
+	}))
+	defer server.Close()
+
+	project := curseforge.Project{Id: 1, GameId: 432, Links: curseforge.Links{WebsiteUrl: "https://www.curseforge.com/minecraft/mc-mods/example"}}
+	file := curseforge.File{
+		Id:           10,
+		DownloadUrl:  server.URL,
+		ReleaseType:  2,
+		FileDate:     time.Now(),
+		GameVersions: []string{"1.20.1", "Forge"},
+	}
+
+	versions, err := getModVersions(project, file, ctx)
+	if !assert.NoError(t, err) || !assert.Len(t, versions, 2) {
+		return
+	}
+
+	//second lookup is served from the database, and must still have both mods
+	file.ReleaseType = 1
+	file.GameVersions = []string{"1.20.1", "1.20", "Forge"}
+	versions, err = getModVersions(project, file, ctx)
+	if !assert.NoError(t, err) || !assert.Len(t, versions, 2) {
+		return
+	}
+	assert.Equal(t, int32(1), downloads.Load())
+
+	db, err := database.Get(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var stored []*models.Version
+	err = db.Where(&models.Version{FileId: file.Id}).Order("mod_id").Find(&stored).Error
+	if !assert.NoError(t, err) || !assert.Len(t, stored, 2) {
+		return
+	}
+	for _, v := range stored {
+		assert.Equal(t, int8(1), v.Type)
+		assert.Equal(t, "1.20.1,1.20,Forge", v.GameVersions)
+	}
+
+	versionMap := newVersionMap(versions...)
+	for _, modId := range []string{"examplemod", "examplelib"} {
+		promos := getPromos(project, versionMap, modId, "forge", UpdateOptions{}, ctx)
+		assert.Equal(t, map[string]string{
+			"1.20.1-latest":      "1.0.0",
+			"1.20.1-recommended": "1.0.0",
+			"1.20-latest":        "1.0.0",
+			"1.20-recommended":   "1.0.0",
+		}, promos.Promos, modId)
+	}
+}
+
+func Test_ArchiveLimits(t *testing.T) {
+	//a few MB of the same byte compresses far past the allowed ratio
+	bomb := buildZip(t, map[string]string{
+		"META-INF/mods.toml": testTOML + strings.Repeat(" ", 4*1024*1024),
+	})
+	r, err := zip.NewReader(bytes.NewReader(bomb), int64(len(bomb)))
+	if !assert.NoError(t, err, "error reading file") {
+		return
+	}
+	_, err = parseJarFile(r, context.Background())
+	assert.ErrorIs(t, err, ErrArchiveLimit)
+
+	files := map[string]string{"META-INF/mods.toml": testTOML}
+	for i := range 10 {
+		files[fmt.Sprintf("assets/%d.txt", i)] = ""
+	}
+	data := buildZip(t, files)
+	r, err = zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !assert.NoError(t, err, "error reading file") {
+		return
+	}
+
+	original := limits
+	t.Cleanup(func() { limits = original })
+	limits.Entries = 5
+	_, err = parseJarFile(r, context.Background())
+	assert.ErrorIs(t, err, ErrArchiveLimit)
+
+	limits.Entries = original.Entries
+	modInfo, err := parseJarFile(r, context.Background())
+	if assert.NoError(t, err) && assert.NotNil(t, modInfo) {
+		assert.Equal(t, "examplemod", modInfo.Mods[0].ModId)
+	}
+}
+
+func Test_FileFailures(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+
+	var downloads atomic.Int32
+	var broken atomic.Bool
+	broken.Store(true)
+	jar := buildZip(t, map[string]string{"META-INF/mods.toml": testTOML})
+	bomb := buildZip(t, map[string]string{"META-INF/mods.toml": testTOML + strings.Repeat(" ", 4*1024*1024)})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		switch {
+		case r.URL.Path == "/corrupt":
+			_, _ = w.Write([]byte("not a zip"))
+		case r.URL.Path == "/bomb":
+			_, _ = w.Write(bomb)
+		case broken.Load():
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			_, _ = w.Write(jar)
+		}
+	}))
+	defer server.Close()
+
+	project := curseforge.Project{Id: 1, GameId: 432}
+	corrupt := curseforge.File{Id: 20, DownloadUrl: server.URL + "/corrupt", GameVersions: []string{"1.19.2"}}
+	flaky := curseforge.File{Id: 21, DownloadUrl: server.URL + "/flaky", GameVersions: []string{"1.19.2"}}
+
+	db, err := database.Get(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = getModVersions(project, corrupt, ctx)
+	assert.ErrorIs(t, err, zip.ErrFormat)
+	_, err = getModVersions(project, corrupt, ctx)
+	assert.ErrorIs(t, err, ErrRetryLater)
+
+	failure, err := getFileFailure(db, corrupt.Id)
+	if assert.NoError(t, err) && assert.NotNil(t, failure) {
+		assert.Equal(t, failureCorrupt, failure.ErrorClass)
+		assert.True(t, failure.Permanent)
+		assert.Equal(t, 1, failure.Attempts)
+	}
+
+	//files over the limits will never get smaller, and get no placeholder row either
+	bombFile := curseforge.File{Id: 22, DownloadUrl: server.URL + "/bomb", GameVersions: []string{"1.19.2"}}
+	_, err = getModVersions(project, bombFile, ctx)
+	assert.ErrorIs(t, err, ErrArchiveLimit)
+	_, err = getModVersions(project, bombFile, ctx)
+	assert.ErrorIs(t, err, ErrRetryLater)
+
+	failure, err = getFileFailure(db, bombFile.Id)
+	if assert.NoError(t, err) && assert.NotNil(t, failure) {
+		assert.Equal(t, failureLimit, failure.ErrorClass)
+		assert.True(t, failure.Permanent)
+	}
+	var stored int64
+	assert.NoError(t, db.Model(&models.Version{}).Where("file_id = ?", bombFile.Id).Count(&stored).Error)
+	assert.Equal(t, int64(0), stored)
+
+	//they can still be reparsed by hand, once the limits have been raised
+	files, err := addFailedFiles(db, nil, project.Id, bombFile.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, []outdatedFile{{CurseId: project.Id, FileId: bombFile.Id}}, files)
+
+	_, err = getModVersions(project, flaky, ctx)
+	assert.ErrorIs(t, err, errDownloadFailed)
+	_, err = getModVersions(project, flaky, ctx)
+	assert.ErrorIs(t, err, ErrRetryLater)
+	assert.Equal(t, int32(3), downloads.Load())
+
+	failure, err = getFileFailure(db, flaky.Id)
+	if !assert.NoError(t, err) || !assert.NotNil(t, failure) {
+		return
+	}
+	assert.Equal(t, failureDownload, failure.ErrorClass)
+	assert.False(t, failure.Permanent)
+
+	//once the backoff has passed the file is tried again, and the failure is cleared when it works
+	broken.Store(false)
+	failure.NextRetry = time.Now().Add(-time.Second)
+	if !assert.NoError(t, db.Save(failure).Error) {
+		return
+	}
+	versions, err := getModVersions(project, flaky, ctx)
+	if assert.NoError(t, err) && assert.Len(t, versions, 1) {
+		assert.Equal(t, "examplemod", versions[0].ModId)
+	}
+	failure, err = getFileFailure(db, flaky.Id)
+	assert.NoError(t, err)
+	assert.Nil(t, failure)
+}
+
+func Test_Reparse(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+
+	jar := buildZip(t, map[string]string{"META-INF/mods.toml": testMultiModTOML})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(jar)
+	}))
+	defer server.Close()
+
+	db, err := database.Get(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	//rows written by an older parser, one which found the mod and one which found nothing
+	old := []*models.Version{
+		{CurseId: 1, FileId: 30, ModId: "examplemod", Version: "1.0.0", GameVersions: "1.20.1"},
+		{CurseId: 1, FileId: 31, GameVersions: "1.20.1"},
+	}
+	for _, v := range old {
+		if !assert.NoError(t, db.Create(v).Error) {
+			return
+		}
+	}
+	if !assert.NoError(t, saveDependencies(db, old[0].Id, []models.Dependency{{ModId: "forge"}})) {
+		return
+	}
+
+	files, err := findOutdatedFiles(db, 10)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []outdatedFile{{CurseId: 1, FileId: 31}, {CurseId: 1, FileId: 30}}, files)
+
+	project := curseforge.Project{Id: 1, GameId: 432}
+	file := curseforge.File{Id: 30, DownloadUrl: server.URL, GameVersions: []string{"1.20.1"}}
+	versions, err := getModVersions(project, file, ctx)
+	if !assert.NoError(t, err) || !assert.Len(t, versions, 2) {
+		return
+	}
+	for _, v := range versions {
+		assert.Equal(t, parserVersion, v.ParserVersion)
+	}
+
+	var stored []*models.Version
+	assert.NoError(t, db.Where(&models.Version{FileId: 30}).Find(&stored).Error)
+	assert.Len(t, stored, 2)
+
+	var deps []*models.VersionDependency
+	assert.NoError(t, db.Where(&models.VersionDependency{VersionId: old[0].Id}).Find(&deps).Error)
+	assert.Empty(t, deps)
+
+	files, err = findOutdatedFiles(db, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []outdatedFile{{CurseId: 1, FileId: 31}}, files)
+}
+
+func Test_JarStore(t *testing.T) {
+	ctx := context.Background()
+
+	jar := buildZip(t, map[string]string{
+		"META-INF/mods.toml":        testTOML,
+		"assets/example/icon.png":   "not really a png",
+		"com/example/Example.class": "not really a class",
+	})
+	hash := sha1.Sum(jar)
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		_, _ = w.Write(jar)
+	}))
+	defer server.Close()
+
+	project := curseforge.Project{Id: 1, GameId: 432}
+	file := curseforge.File{
+		Id:          40,
+		DownloadUrl: server.URL,
+		Hashes:      []curseforge.FileHash{{Value: hex.EncodeToString(hash[:]), Algo: curseforge.HashAlgoSha1}},
+	}
+
+	for _, mode := range []store.Mode{store.ModeFull, store.ModeMetadata} {
+		t.Run(string(mode), func(t *testing.T) {
+			store.Initialize(t.TempDir(), 1024*1024, mode)
+			t.Cleanup(func() { store.Initialize("", 0, store.ModeFull) })
+			downloads.Store(0)
+
+			for range 2 {
+				modInfo, err := readFile(project, file, ctx)
+				if assert.NoError(t, err) && assert.NotNil(t, modInfo) {
+					assert.Equal(t, "examplemod", modInfo.Mods[0].ModId)
+				}
+			}
+			assert.Equal(t, int32(1), downloads.Load())
+
+			f, size, err := store.Get(hex.EncodeToString(hash[:]))
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer f.Close()
+			r, err := zip.NewReader(f, size)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if mode == store.ModeMetadata {
+				assert.Len(t, r.File, 1)
+			} else {
+				assert.Len(t, r.File, 3)
+			}
+		})
+	}
+
+	//a jar only described by its @Mod annotation keeps its classes, even though it has a manifest
+	legacy := buildZip(t, map[string]string{
+		"META-INF/MANIFEST.MF":      "Manifest-Version: 1.0\n",
+		"com/example/Example.class": string(buildModClass(t, "Lnet/minecraftforge/fml/common/Mod;")),
+	})
+	legacyHash := sha1.Sum(legacy)
+	_, ok := buildMetadataZip(bytes.NewReader(legacy), int64(len(legacy)))
+	assert.False(t, ok)
+
+	store.Initialize(t.TempDir(), 1024*1024, store.ModeMetadata)
+	legacyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(legacy)
+	}))
+	defer legacyServer.Close()
+	legacyFile := curseforge.File{
+		Id:          41,
+		DownloadUrl: legacyServer.URL,
+		Hashes:      []curseforge.FileHash{{Value: hex.EncodeToString(legacyHash[:]), Algo: curseforge.HashAlgoSha1}},
+	}
+	for range 2 {
+		modInfo, err := readFile(project, legacyFile, ctx)
+		if assert.NoError(t, err) && assert.NotNil(t, modInfo) {
+			assert.Equal(t, "examplemod", modInfo.Mods[0].ModId)
+			assert.Equal(t, "1.2.3", modInfo.Mods[0].Version)
+		}
+	}
+	f, size, err := store.Get(hex.EncodeToString(legacyHash[:]))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(len(legacy)), size)
+		_ = f.Close()
+	}
+
+	//files CurseForge gives no hash for could never be read back, so they are not stored
+	store.Initialize(t.TempDir(), 1024*1024, store.ModeFull)
+	t.Cleanup(func() { store.Initialize("", 0, store.ModeFull) })
+	file.Hashes = nil
+	_, err = readFile(project, file, ctx)
+	assert.NoError(t, err)
+	count, _, err := store.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func Test_Commands(t *testing.T) {
+	setupDatabase(t)
+
+	path := filepath.Join(t.TempDir(), "examplemod.jar")
+	if !assert.NoError(t, os.WriteFile(path, buildZip(t, map[string]string{"META-INF/mods.toml": testTOML}), 0644)) {
+		return
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 0, runCommand([]string{"inspect", path}, &stdout, &stderr))
+	var result models.InspectResult
+	if assert.NoError(t, json.Unmarshal(stdout.Bytes(), &result)) && assert.Len(t, result.Mods, 1) {
+		assert.Equal(t, "examplemod", result.Mods[0].ModId)
+	}
+
+	stdout.Reset()
+	assert.Equal(t, 0, runCommand([]string{"db", "stats"}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "Versions:")
+
+	stdout.Reset()
+	assert.Equal(t, 2, runCommand([]string{"reparse", "--unknown"}, &stdout, &stderr))
+	assert.Equal(t, 2, runCommand([]string{"index"}, &stdout, &stderr))
+	assert.Equal(t, 2, runCommand([]string{"cache", "purge"}, &stdout, &stderr))
+	assert.Equal(t, 1, runCommand([]string{"store", "purge"}, &stdout, &stderr))
+
+	//only the commands which use the database care about its settings
+	t.Setenv("DB_ENGINE", "postgres")
+	assert.Equal(t, 0, runCommand([]string{"inspect", path}, &stdout, &stderr))
+	stderr.Reset()
+	assert.Equal(t, 1, runCommand([]string{"db", "stats"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "database.engine")
+	assert.Equal(t, 1, runCommand([]string{"inspect", filepath.Join(t.TempDir(), "missing.jar")}, &stdout, &stderr))
+}
+
+func Test_InspectJar(t *testing.T) {
+	modsToml := strings.Replace(testTOML, `version="1.0.0.0"`, `version="${file.jarVersion}"`, 1)
+	data := buildZip(t, map[string]string{
+		"META-INF/mods.toml":   modsToml,
+		"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\nImplementation-Version: 2.3.4\n",
+		"fabric.mod.json":      "{not json",
+	})
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", "examplemod.jar")
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, _ = part.Write(data)
+	_ = w.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/inspect", inspectJar)
+
+	req := httptest.NewRequest(http.MethodPost, "/inspect", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	if !assert.Equal(t, http.StatusOK, recorder.Code) {
+		return
+	}
+
+	var result models.InspectResult
+	if !assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result)) || !assert.Len(t, result.Mods, 1) {
+		return
+	}
+
+	assert.Equal(t, []string{"forge"}, result.Loaders)
+	assert.Equal(t, "2.3.4", result.ManifestVersion)
+	assert.Equal(t, "examplemod", result.Mods[0].ModId)
+	assert.Equal(t, "2.3.4", result.Mods[0].Version)
+	assert.Equal(t, "${file.jarVersion}", result.Mods[0].DeclaredVersion)
+	assert.Equal(t, "[1.19,1.20)", result.Mods[0].McVersionRange)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, "fabric.mod.json", result.Errors[0].Entry)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/inspect", strings.NewReader(""))
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func Test_DebugReport(t *testing.T) {
+	now := time.Now()
+	versions := []*models.Version{
+		{FileId: 4, ModId: "examplemod", Version: "1.3.0", Loader: "fabric", GameVersions: "1.20.1,Fabric", ReleaseDate: now},
+		{FileId: 3, ModId: "examplemod", Version: "1.2.0", Loader: "forge", GameVersions: "1.20.1,Forge", ReleaseDate: now.Add(-time.Hour), Type: 1},
+		{FileId: 2, ModId: "examplemod", Version: "1.1.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now.Add(-2 * time.Hour), Type: 2},
+		{FileId: 1, ModId: "otherlib", Version: "1.0.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now.Add(-3 * time.Hour)},
+	}
+	versionMap := newVersionMap(versions...)
+	fileErrors := map[uint]error{5: errors.New("download failed")}
+
+	trace := newPromoTrace()
+	promos := tracePromos(curseforge.Project{}, versionMap, "examplemod", "forge", UpdateOptions{}, trace, context.Background())
+	failures := map[uint]*models.FileFailure{5: {FileId: 5, ErrorClass: failureDownload, Attempts: 1}}
+	report := buildDebugReport(promos, versionMap, fileErrors, failures, trace)
+
+	assert.Equal(t, map[string]string{"1.20.1-latest": "1.2.0", "1.20.1-recommended": "1.2.0"}, report.Promos)
+	if !assert.Len(t, report.Files, 5) {
+		return
+	}
+
+	assert.Equal(t, decisionError, report.Files[0].Decision)
+	assert.Equal(t, "download failed", report.Files[0].Reason)
+	assert.Equal(t, failures[5], report.Files[0].Failure)
+
+	assert.Equal(t, decisionSkipped, report.Files[1].Decision)
+	assert.Equal(t, "loader fabric does not match forge", report.Files[1].Reason)
+
+	assert.Equal(t, decisionPromoted, report.Files[2].Decision)
+	assert.Equal(t, []string{"1.20.1-latest", "1.20.1-recommended"}, report.Files[2].Won)
+	assert.Equal(t, []string{"Forge"}, report.Files[2].DroppedGameVersions)
+
+	assert.Equal(t, decisionOutranked, report.Files[3].Decision)
+	assert.Equal(t, []models.LostKey{{Key: "1.20.1-latest", FileId: 3, ModId: "examplemod", Version: "1.2.0"}}, report.Files[3].Lost)
+
+	assert.Equal(t, decisionSkipped, report.Files[4].Decision)
+	assert.Equal(t, "mod id otherlib does not match", report.Files[4].Reason)
+}
+
+func Test_PromotionPolicy(t *testing.T) {
+	now := time.Now()
+	versions := []*models.Version{
+		{FileId: 5, ModId: "examplemod", Version: "1.4.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now, Type: 3},
+		{FileId: 4, ModId: "examplemod", Version: "1.3.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now.Add(-time.Hour), Type: 1},
+		{FileId: 3, ModId: "examplemod", Version: "1.2.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now.Add(-48 * time.Hour), Type: 1},
+		{FileId: 2, ModId: "examplemod", Version: "1.1.0", Loader: "forge", GameVersions: "1.19.2", ReleaseDate: now.Add(-72 * time.Hour), Type: 2},
+		{FileId: 1, ModId: "examplemod", Version: "1.0.0", Loader: "forge", GameVersions: "1.19.2", ReleaseDate: now.Add(-96 * time.Hour), Type: 1},
+	}
+	versionMap := newVersionMap(versions...)
+	project := curseforge.Project{Id: 1}
+
+	t.Cleanup(func() { promotion = config.Default().Promotion })
+
+	promos := tracePromos(project, versionMap, "examplemod", "forge", UpdateOptions{}, nil, context.Background())
+	assert.Equal(t, map[string]string{
+		"1.20.1-latest": "1.4.0", "1.20.1-recommended": "1.3.0",
+		"1.19.2-latest": "1.1.0", "1.19.2-recommended": "1.0.0",
+	}, promos.Promos)
+
+	promotion = config.Promotion{
+		Default: config.PromotionPolicy{Latest: "alpha"},
+		Projects: map[string]config.PromotionPolicy{
+			"1": {Latest: "release", RecommendedDelay: config.Duration(24 * time.Hour), Pinned: map[string]string{"1.19.2-recommended": "1.1.0"}},
+		},
+	}
+	promos = tracePromos(project, versionMap, "examplemod", "forge", UpdateOptions{}, nil, context.Background())
+	assert.Equal(t, map[string]string{
+		"1.20.1-latest": "1.3.0", "1.20.1-recommended": "1.2.0",
+		"1.19.2-latest": "1.0.0", "1.19.2-recommended": "1.1.0",
+	}, promos.Promos)
+
+	//a pinned beta isn't used when only releases were asked for
+	promos = tracePromos(project, versionMap, "examplemod", "forge", UpdateOptions{Channel: "release"}, nil, context.Background())
+	assert.Equal(t, "1.0.0", promos.Promos["1.19.2-recommended"])
+
+	//nor for a Minecraft version the file isn't tagged for
+	promotion.Projects["1"] = config.PromotionPolicy{Pinned: map[string]string{"1.20.1-recommended": "1.1.0"}}
+	promos = tracePromos(project, versionMap, "examplemod", "forge", UpdateOptions{}, nil, context.Background())
+	assert.Equal(t, "1.3.0", promos.Promos["1.20.1-recommended"])
+	assert.Equal(t, "1.0.0", promos.Promos["1.19.2-recommended"])
+
+	//other projects keep the default
+	promos = tracePromos(curseforge.Project{Id: 2}, versionMap, "examplemod", "forge", UpdateOptions{}, nil, context.Background())
+	assert.Equal(t, "1.4.0", promos.Promos["1.20.1-latest"])
+
+	delete(versionMap, VersionKey{FileId: 1, ModId: "examplemod"})
+	promotion.Projects["1"] = config.PromotionPolicy{BetaFallback: true}
+	promos = tracePromos(project, versionMap, "examplemod", "forge", UpdateOptions{}, nil, context.Background())
+	assert.Equal(t, "1.1.0", promos.Promos["1.19.2-recommended"])
+	assert.Equal(t, "1.3.0", promos.Promos["1.20.1-recommended"])
+}
+
+func Test_Channels(t *testing.T) {
+	now := time.Now()
+	versions := []*models.Version{
+		{FileId: 4, ModId: "examplemod", Version: "1.3.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now, Type: 3},
+		{FileId: 3, ModId: "examplemod", Version: "1.2.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now.Add(-time.Hour), Type: 2},
+		{FileId: 2, ModId: "examplemod", Version: "1.1.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now.Add(-2 * time.Hour), Type: 1},
+		{FileId: 1, ModId: "examplemod", Version: "1.0.0", Loader: "forge", GameVersions: "1.19.2", ReleaseDate: now.Add(-3 * time.Hour)},
+	}
+	versionMap := newVersionMap(versions...)
+
+	promos := tracePromos(curseforge.Project{}, versionMap, "examplemod", "forge", UpdateOptions{}, nil, context.Background())
+	assert.Equal(t, map[string]string{"1.20.1-latest": "1.3.0", "1.20.1-recommended": "1.1.0", "1.19.2-latest": "1.0.0"}, promos.Promos)
+
+	promos = tracePromos(curseforge.Project{}, versionMap, "examplemod", "forge", UpdateOptions{Channel: "beta"}, nil, context.Background())
+	assert.Equal(t, map[string]string{"1.20.1-latest": "1.2.0", "1.20.1-recommended": "1.1.0"}, promos.Promos)
+
+	promos = tracePromos(curseforge.Project{}, versionMap, "examplemod", "forge", UpdateOptions{Channel: "release", ChannelKeys: true}, nil, context.Background())
+	assert.Equal(t, map[string]string{"1.20.1-latest": "1.1.0", "1.20.1-recommended": "1.1.0"}, promos.Promos)
+
+	promos = tracePromos(curseforge.Project{}, versionMap, "examplemod", "forge", UpdateOptions{Channel: "beta", ChannelKeys: true}, nil, context.Background())
+	assert.Equal(t, map[string]string{"1.20.1-latest": "1.2.0", "1.20.1-recommended": "1.1.0", "1.20.1-beta": "1.2.0"}, promos.Promos)
+
+	promos = tracePromos(curseforge.Project{}, versionMap, "examplemod", "forge", UpdateOptions{ChannelKeys: true}, nil, context.Background())
+	assert.Equal(t, "1.2.0", promos.Promos["1.20.1-beta"])
+	assert.Equal(t, "1.3.0", promos.Promos["1.20.1-alpha"])
+	assert.NotContains(t, promos.Promos, "1.19.2-beta")
+
+	_, err := getUpdateJson(1, "examplemod", "forge", UpdateOptions{Channel: "nightly"}, context.Background())
+	assert.ErrorIs(t, err, ErrInvalidChannel)
+}
+
+func Test_PointInTime(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+
+	db, err := database.Get(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pageUrl := "https://www.curseforge.com/minecraft/mc-mods/examplemod"
+	versions := []*models.Version{
+		{CurseId: 1, FileId: 1, ModId: "examplemod", Version: "1.0.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: day, Type: 1, Url: pageUrl + "/files/1"},
+		{CurseId: 1, FileId: 2, ModId: "examplemod", Version: "1.1.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: day.Add(48 * time.Hour), Type: 1, Url: pageUrl + "/files/2"},
+	}
+	if !assert.NoError(t, db.Create(versions).Error) {
+		return
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/:projectId/:modId", servePointInTime(promosResponse), readFromCache, processRequest)
+	r.GET("/:projectId/:modId/references", servePointInTime(referencesResponse), readFromCache, getReferences)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		return recorder
+	}
+
+	recorder := get("/1/examplemod?at=2024-01-02T00:00:00Z")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"promos":{"1.20.1-latest":"1.0.0","1.20.1-recommended":"1.0.0"},"homepage":"`+pageUrl+`"}`, recorder.Body.String())
+
+	recorder = get("/1/examplemod/references?at=2024-01-05T00:00:00Z")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"1.20.1-latest":"`+pageUrl+`/files/2","1.20.1-recommended":"`+pageUrl+`/files/2"}`, recorder.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get("/1/examplemod?at=2023-01-01T00:00:00Z").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/1/examplemod?at=yesterday").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/1/examplemod?at=2024-01-02T00:00:00Z&channel=nightly").Code)
+}
+
+func Test_PromoHistory(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+
+	db, err := database.Get(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, recordSnapshot(1, "examplemod", "forge", map[string]string{}, ctx))
+	assert.NoError(t, recordSnapshot(1, "examplemod", "forge", map[string]string{"1.20.1-latest": "1.0.0"}, ctx))
+	assert.NoError(t, recordSnapshot(1, "examplemod", "forge", map[string]string{"1.20.1-latest": "1.0.0"}, ctx))
+	assert.NoError(t, recordSnapshot(1, "examplemod", "forge", map[string]string{"1.20.1-latest": "1.1.0", "1.20.1-recommended": "1.1.0"}, ctx))
+	assert.NoError(t, recordSnapshot(1, "examplemod", "forge", map[string]string{"1.20.1-latest": "1.2.0"}, ctx))
+
+	var snapshots []*models.PromoSnapshot
+	if !assert.NoError(t, db.Order("id asc").Find(&snapshots).Error) || !assert.Len(t, snapshots, 3) {
+		return
+	}
+
+	//a request which read the same last snapshot as one before it can't record a change after it as well
+	racing := &models.PromoSnapshot{CurseId: 1, ModId: "examplemod", Loader: "forge", PreviousId: snapshots[2].PreviousId, Promos: "{}"}
+	assert.NoError(t, db.Clauses(clause.OnConflict{DoNothing: true}).Create(racing).Error)
+	var count int64
+	assert.NoError(t, db.Model(&models.PromoSnapshot{}).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for k, v := range snapshots {
+		db.Model(v).Update("created_at", day.Add(time.Duration(k)*24*time.Hour))
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/:projectId/:modId/history", getPromoHistory)
+	r.GET("/:projectId/:modId/diff", getPromoDiff)
+
+	get := func(url string) (int, []models.PromoChange) {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		var changes []models.PromoChange
+		if recorder.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &changes))
+		}
+		return recorder.Code, changes
+	}
+
+	code, changes := get("/1/examplemod/history")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []models.PromoChange{
+		{Key: "1.20.1-latest", From: "1.1.0", To: "1.2.0", ChangedAt: day.Add(48 * time.Hour)},
+		{Key: "1.20.1-recommended", From: "1.1.0", ChangedAt: day.Add(48 * time.Hour)},
+		{Key: "1.20.1-latest", From: "1.0.0", To: "1.1.0", ChangedAt: day.Add(24 * time.Hour)},
+		{Key: "1.20.1-recommended", To: "1.1.0", ChangedAt: day.Add(24 * time.Hour)},
+		{Key: "1.20.1-latest", To: "1.0.0", ChangedAt: day},
+	}, changes)
+
+	code, changes = get("/1/examplemod/diff?since=2024-01-01T12:00:00Z")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []models.PromoChange{
+		{Key: "1.20.1-latest", From: "1.0.0", To: "1.2.0", ChangedAt: day.Add(48 * time.Hour)},
+	}, changes)
+
+	code, changes = get("/1/examplemod/diff?since=2023-01-01T00:00:00Z")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []models.PromoChange{
+		{Key: "1.20.1-latest", To: "1.2.0", ChangedAt: day.Add(48 * time.Hour)},
+	}, changes)
+
+	code, changes = get("/1/othermod/history")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, changes)
+
+	code, _ = get("/1/examplemod/diff")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func Test_ExtendedFormat(t *testing.T) {
+	released := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	version := &models.Version{
+		FileId: 1, ModId: "examplemod", Version: "1.0.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: released,
+		Type: 2, Url: "https://www.curseforge.com/minecraft/mc-mods/examplemod/files/1", FileName: "examplemod-1.0.0.jar",
+		DownloadUrl: "https://edge.forgecdn.net/files/0/1/examplemod-1.0.0.jar",
+	}
+	versionMap := newVersionMap(version)
+	data := getPromos(curseforge.Project{}, versionMap, "examplemod", "forge", UpdateOptions{}, context.Background())
+
+	plain, err := json.Marshal(promosResponse(data, UpdateOptions{}))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"promos":{"1.20.1-latest":"1.0.0"},"homepage":""}`, string(plain))
+
+	extended, err := json.Marshal(promosResponse(data, UpdateOptions{Format: formatExtended}))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"promos": {"1.20.1-latest": "1.0.0"},
+		"files": {
+			"1.20.1-latest": {
+				"version": "1.0.0",
+				"fileId": 1,
+				"fileName": "examplemod-1.0.0.jar",
+				"releaseType": "beta",
+				"releaseDate": "2024-01-01T00:00:00Z",
+				"url": "https://www.curseforge.com/minecraft/mc-mods/examplemod/files/1",
+				"downloadUrl": "https://edge.forgecdn.net/files/0/1/examplemod-1.0.0.jar"
+			}
+		},
+		"homepage": ""
+	}`, string(extended))
+
+	assert.ErrorIs(t, UpdateOptions{Format: "xml"}.validate(), ErrInvalidFormat)
+	assert.True(t, UpdateOptions{Format: formatExtended}.isDefault())
+	assert.False(t, UpdateOptions{Channel: "beta"}.isDefault())
+}
+
+func Test_AllLoaders(t *testing.T) {
+	setupDatabase(t)
+	now := time.Now()
+	versions := []*models.Version{
+		{FileId: 4, ModId: "examplemod", Version: "1.3.0", Loader: "fabric,quilt", GameVersions: "1.20.1", ReleaseDate: now, Type: 1},
+		{FileId: 3, ModId: "examplemod", Version: "1.2.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now.Add(-time.Hour), Type: 1},
+		{FileId: 2, GameVersions: "1.20.1", ReleaseDate: now.Add(-2 * time.Hour)},
+		{FileId: 1, ModId: "otherlib", Version: "1.0.0", Loader: "rift", GameVersions: "1.12.2", ReleaseDate: now.Add(-3 * time.Hour)},
+		{FileId: 5, ModId: "examplepack", Version: "1.0.0", Loader: "resourcepack", GameVersions: "1.20.1", ReleaseDate: now, Type: 1},
+	}
+	versionMap := newVersionMap(versions...)
+
+	assert.Equal(t, []string{"fabric", "forge", "quilt"}, getModLoaders(versionMap, "examplemod"))
+
+	//a pack has no loader of its own, so it is listed under its pack type
+	assert.Equal(t, []string{"resourcepack"}, getModLoaders(versionMap, "examplepack"))
+	packs := getAllPromos(curseforge.Project{Id: 1}, versionMap, "examplepack", UpdateOptions{}, context.Background())
+	if assert.Contains(t, packs, "resourcepack") {
+		assert.Equal(t, "1.0.0", packs["resourcepack"].Promos["1.20.1-latest"])
+	}
+
+	all := getAllPromos(curseforge.Project{Id: 1}, versionMap, "examplemod", UpdateOptions{}, context.Background())
+	if !assert.Len(t, all, 3) {
+		return
+	}
+	for _, loader := range []string{"fabric", "forge", "quilt"} {
+		expected := getPromos(curseforge.Project{Id: 1}, versionMap, "examplemod", loader, UpdateOptions{}, context.Background())
+		assert.Equal(t, expected.Promos, all[loader].Promos, loader)
+	}
+	assert.Equal(t, "1.3.0", all["quilt"].Promos["1.20.1-latest"])
+	assert.Equal(t, "1.2.0", all["forge"].Promos["1.20.1-latest"])
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/:projectId/:modId/expire", expireCache)
+
+	cache.Set("example.com/1/examplemod/all", http.StatusOK, nil)
+	cache.Set("example.com/1/examplemod?ml=fabric", http.StatusOK, nil)
+	cache.Set("example.com/1", http.StatusOK, nil)
+	req := httptest.NewRequest(http.MethodGet, "/1/examplemod/expire?ml=fabric", nil)
+	req.Host = "example.com"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	for _, key := range []string{"example.com/1/examplemod/all", "example.com/1/examplemod?ml=fabric", "example.com/1"} {
+		_, exists := cache.Get(key)
+		assert.False(t, exists, key)
+	}
+
+	//expiring on a loader subdomain clears the combined responses on the root host, whatever their options
+	oldHost := host
+	host = "example.com"
+	t.Cleanup(func() { host = oldHost })
+
+	cache.Set("example.com/1/examplemod/all", http.StatusOK, nil)
+	cache.Set("example.com/1/examplemod/all?expand=true", http.StatusOK, nil)
+	cache.Set("example.com/1/examplemod/all?format=extended", http.StatusOK, nil)
+	cache.Set("example.com/1/othermod/all", http.StatusOK, nil)
+	cache.Set("example.com/1", http.StatusOK, nil)
+	cache.Set("example.com/1?channel=beta", http.StatusOK, nil)
+	req = httptest.NewRequest(http.MethodGet, "/1/examplemod/expire", nil)
+	req.Host = "fabric.example.com"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	for _, key := range []string{"example.com/1/examplemod/all", "example.com/1/examplemod/all?expand=true", "example.com/1/examplemod/all?format=extended", "example.com/1", "example.com/1?channel=beta"} {
+		_, exists := cache.Get(key)
+		assert.False(t, exists, key)
+	}
+	_, exists := cache.Get("example.com/1/othermod/all")
+	assert.True(t, exists, "other mods are kept")
+}
+
+func Test_ProjectPromos(t *testing.T) {
+	setupDatabase(t)
+	now := time.Now()
+	versions := []*models.Version{
+		{FileId: 3, ModId: "examplemod", Version: "1.2.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now, Type: 1},
+		{FileId: 3, ModId: "examplelib", Version: "2.0.0", Loader: "forge", GameVersions: "1.20.1", ReleaseDate: now, Type: 1},
+		{FileId: 2, ModId: "examplemod", Version: "1.1.0", Loader: "fabric", GameVersions: "1.20.1", ReleaseDate: now.Add(-time.Hour), Type: 1},
+		{FileId: 1, GameVersions: "1.20.1", ReleaseDate: now.Add(-2 * time.Hour)},
+	}
+	versionMap := newVersionMap(versions...)
+
+	modIds := getModIds(versionMap)
+	assert.Equal(t, []string{"examplelib", "examplemod"}, modIds)
+
+	project := curseforge.Project{Id: 1}
+	assert.Equal(t, []string{"forge"}, slices.Collect(maps.Keys(getAllPromos(project, versionMap, "examplelib", UpdateOptions{}, context.Background()))))
+	promos := getAllPromos(project, versionMap, "examplemod", UpdateOptions{}, context.Background())
+	assert.Equal(t, "1.1.0", promos["fabric"].Promos["1.20.1-latest"])
+	assert.Equal(t, "1.2.0", promos["forge"].Promos["1.20.1-latest"])
+
+	//any other path ends up here, which isn't worth remembering
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/:projectId", readFromCache, getProjectPromos)
+	req := httptest.NewRequest(http.MethodGet, "/favicon.ico", nil)
+	req.Host = "example.com"
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	_, exists := cache.Get("example.com/favicon.ico")
+	assert.False(t, exists)
+}
+
+func Test_ProjectIndex(t *testing.T) {
+	setupDatabase(t)
+	ctx := context.Background()
+
+	db, err := database.Get(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pageUrl := "https://www.curseforge.com/minecraft/mc-mods/examplemod"
+	versions := []*models.Version{
+		{CurseId: 1, FileId: 2, ModId: "examplemod", Version: "1.1.0", Loader: "forge", GameVersions: "1.20.1,Forge", Url: pageUrl + "/files/2"},
+		{CurseId: 1, FileId: 2, ModId: "examplelib", Version: "2.0.0", Loader: "forge", GameVersions: "1.20.1,Forge", Url: pageUrl + "/files/2"},
+		{CurseId: 1, FileId: 1, ModId: "examplemod", Version: "1.0.0", Loader: "fabric", GameVersions: "1.19.2,1.9.4", Url: pageUrl + "/files/1"},
+		{CurseId: 2, FileId: 3, ModId: "examplepack", Version: "1.0.0", Loader: "resourcepack", GameVersions: "1.20.1", Url: "https://www.curseforge.com/minecraft/texture-packs/examplepack/files/3"},
+	}
+	if !assert.NoError(t, db.Create(versions).Error) {
+		return
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/projects/:project", getProjectIndex)
+
+	expected := models.ProjectIndex{
+		ProjectId: 1,
+		HomePage:  pageUrl,
+		Mods: []models.IndexedMod{
+			{
+				ModId:        "examplelib",
+				Loaders:      []string{"forge"},
+				GameVersions: []string{"1.20.1"},
+				UpdateUrls:   map[string]string{"forge": "http://example.com/1/examplelib?ml=forge"},
+			},
+			{
+				ModId:        "examplemod",
+				Loaders:      []string{"fabric", "forge"},
+				GameVersions: []string{"1.20.1", "1.19.2", "1.9.4"},
+				UpdateUrls: map[string]string{
+					"fabric": "http://example.com/1/examplemod?ml=fabric",
+					"forge":  "http://example.com/1/examplemod?ml=forge",
+				},
+			},
+		},
+	}
+
+	for _, project := range []string{"1", "examplemod", "ExampleMod"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/projects/"+project, nil)
+		req.Host = "example.com"
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		if !assert.Equal(t, http.StatusOK, recorder.Code, project) {
+			continue
+		}
+
+		var index models.ProjectIndex
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &index))
+		assert.Equal(t, expected, index, project)
+	}
+
+	//packs are served for any loader, so their url doesn't name one
+	req := httptest.NewRequest(http.MethodGet, "/api/projects/examplepack", nil)
+	req.Host = "example.com"
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	var index models.ProjectIndex
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &index))
+	assert.Equal(t, []models.IndexedMod{{
+		ModId:        "examplepack",
+		Loaders:      []string{"resourcepack"},
+		GameVersions: []string{"1.20.1"},
+		UpdateUrls:   map[string]string{"resourcepack": "http://example.com/2/examplepack"},
+	}}, index.Mods)
+
+	recorder = httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/projects/example_%25", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func Test_VersionList(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions := []*models.Version{
+		{FileId: 4, ModId: "examplemod", Version: "1.3.0", Loader: "fabric", GameVersions: "1.20.1,Fabric", ReleaseDate: day.Add(72 * time.Hour), Type: 3, FileName: "examplemod-1.3.0.jar"},
+		{FileId: 3, ModId: "examplemod", Version: "1.2.0", Loader: "forge", GameVersions: "1.20.1,Forge", ReleaseDate: day.Add(48 * time.Hour), Type: 2},
+		{FileId: 2, ModId: "examplemod", Version: "1.1.0", Loader: "forge", GameVersions: "1.19.2", ReleaseDate: day.Add(24 * time.Hour), Type: 1},
+		{FileId: 1, ModId: "examplemod", Version: "1.0.0", Loader: "forge", GameVersions: "1.19.2", ReleaseDate: day, Type: 1},
+		{FileId: 5, ModId: "otherlib", Version: "5.0.0", Loader: "forge", GameVersions: "1.19.2", ReleaseDate: day},
+	}
+	versionMap := newVersionMap(versions...)
+
+	fileIds := func(list *models.VersionList) []uint {
+		ids := make([]uint, 0)
+		for _, v := range list.Versions {
+			ids = append(ids, v.FileId)
+		}
+		return ids
+	}
+
+	list := listVersions(versionMap, "examplemod", versionFilter{Page: 1, PageSize: 50})
+	assert.Equal(t, []uint{4, 3, 2, 1}, fileIds(list))
+	assert.Equal(t, 4, list.Total)
+	assert.Equal(t, models.VersionEntry{
+		Version: "1.3.0", FileId: 4, FileName: "examplemod-1.3.0.jar", GameVersions: []string{"1.20.1"},
+		Loaders: []string{"fabric"}, ReleaseType: "alpha", ReleaseDate: day.Add(72 * time.Hour),
+	}, list.Versions[0])
+
+	list = listVersions(versionMap, "examplemod", versionFilter{Page: 2, PageSize: 3})
+	assert.Equal(t, []uint{1}, fileIds(list))
+	assert.Equal(t, 4, list.Total)
+
+	list = listVersions(versionMap, "examplemod", versionFilter{Page: 3, PageSize: 3})
+	assert.Empty(t, list.Versions)
+
+	list = listVersions(versionMap, "examplemod", versionFilter{Loader: "forge", Channel: 2, Page: 1, PageSize: 50})
+	assert.Equal(t, []uint{3, 2, 1}, fileIds(list))
+
+	list = listVersions(versionMap, "examplemod", versionFilter{GameVersion: "1.19.2", From: day.Add(time.Hour), Page: 1, PageSize: 50})
+	assert.Equal(t, []uint{2}, fileIds(list))
+
+	list = listVersions(versionMap, "examplemod", versionFilter{To: day.Add(24 * time.Hour), Page: 1, PageSize: 50})
+	assert.Equal(t, []uint{1}, fileIds(list))
+
+	list = listVersions(versionMap, "examplemod", versionFilter{Version: "1.2.0", Page: 1, PageSize: 50})
+	assert.Equal(t, []uint{3}, fileIds(list))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/:projectId/:modId/versions", getVersionList)
+	list = listVersions(versionMap, "examplemod", versionFilter{Page: maxPage, PageSize: maxPageSize})
+	assert.Empty(t, list.Versions)
+	assert.Equal(t, 4, list.Total)
+
+	for _, query := range []string{"page=0", "pageSize=500", "channel=nightly", "from=yesterday", "page=4611686018427387904&pageSize=200", "page=10001"} {
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/1/examplemod/versions?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, query)
+	}
+}
+
+func Test_VersionCheck(t *testing.T) {
+	data := &models.UpdateJson{
+		Promos: map[string]string{
+			"1.20.1-latest":      "1.2.0-beta.1",
+			"1.20.1-recommended": "1.1.0",
+			"1.19.2-latest":      "1.0.5",
+			"1.19.2-recommended": "1.0.5",
+			"1.18.2-latest":      "0.9.0",
+		},
+		HomePage: "https://example.com",
+	}
+
+	tests := []struct {
+		current     string
+		gameVersion string
+		expected    models.VersionCheck
+	}{
+		{"1.1.0", "1.20.1", models.VersionCheck{Status: statusUpToDate}},
+		{"1.1.5", "1.20.1", models.VersionCheck{Status: statusOutdated, Target: "1.2.0-beta.1"}},
+		{"1.1.0.0", "1.19.2", models.VersionCheck{Status: statusAhead}},
+		{"1.2.0", "1.20.1", models.VersionCheck{Status: statusAhead}},
+		{"1.0.0", "1.20.1", models.VersionCheck{Status: statusOutdated, Target: "1.1.0"}},
+		{"1.0.5", "1.19.2", models.VersionCheck{Status: statusUpToDate}},
+		{"0.8.0", "1.18.2", models.VersionCheck{Status: statusBetaOutdated, Target: "0.9.0"}},
+		{"0.9", "1.18.2", models.VersionCheck{Status: statusBeta, Target: "0.9.0"}},
+		{"1.0.0", "1.16.5", models.VersionCheck{Status: statusBeta}},
+	}
+	for _, v := range tests {
+		v.expected.HomePage = data.HomePage
+		assert.Equal(t, v.expected, getVersionCheck(data, v.current, v.gameVersion), v.current+" on "+v.gameVersion)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/:projectId/:modId/check", checkVersion)
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/1/examplemod/check?mc=1.20.1", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func Test_RequireToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/debug/vars", requireToken("secret"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for header, status := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		assert.Equal(t, status, recorder.Code, header)
+	}
+}
+
+func setupDatabase(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "updatejson.db")
+	//commands load their config from the environment
+	t.Setenv("DB_ENGINE", "sqlite3")
+	t.Setenv("DB_FILE", file)
+	t.Setenv("DB_MODE", "release")
+	database.Initialize(config.Database{Engine: "sqlite3", File: file, Mode: "release"})
+	recordedPromos.Clear()
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
 }
 
 const testTOML = `modLoader="javafml"
@@ -177,3 +1588,47 @@ Lets you craft dirt into diamonds. This is a traditional mod that has existed fo
     versionRange="[1.19,1.20)"
     ordering="NONE"
     side="BOTH"`
+
+const testMultiModTOML = `modLoader="javafml"
+loaderVersion="[47,)"
+license="MIT"
+
+[[mods]]
+    modId="examplemod"
+    version="1.0.0"
+
+[[mods]]
+    modId="examplelib"
+    version="1.0.0"
+
+[[dependencies.examplemod]]
+    modId="examplelib"
+    mandatory=true
+    versionRange="[1.0,)"
+    ordering="AFTER"
+    side="BOTH"`
+
+const testQuiltJSON = `{
+	"schema_version": 1,
+	"quilt_loader": {
+		"group": "com.example",
+		"id": "examplemod",
+		"version": "2.0.0",
+		"provides": ["example_api", {"id": "example-legacy", "version": "1.0.0"}],
+		"depends": [
+			"quilt_loader",
+			{"id": "minecraft", "versions": {"all": [">=1.20", "<1.20.2"]}},
+			{"id": "modmenu", "versions": ["1.0", "2.0"], "optional": true},
+			[{"id": "fabric-api"}, {"id": "qsl", "versions": ">=6.0"}]
+		],
+		"breaks": [{"id": "oldmod"}],
+		"metadata": {
+			"name": "Example Mod",
+			"description": "Dirt into diamonds",
+			"contributors": {"You": "Contributor", "Me": "Owner"},
+			"license": ["MIT", {"name": "Custom License", "url": "https://example.com"}],
+			"contact": {"homepage": "https://example.com"}
+		}
+	},
+	"minecraft": {"environment": "client"}
+}`
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/cfwidget/updatejson/cache"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+func getModMetadata(c *gin.Context) {
+	pid := c.Param("projectId")
+	modId := c.Param("modId")
+	loader := getLoader(c)
+	mcVersion := c.Query("mc")
+
+	cacheKey := cache.GetKey(c)
+
+	var projectId uint
+	var err error
+	if projectId, err = cast.ToUintE(pid); err != nil {
+		cache.Set(cacheKey, http.StatusNotFound, nil)
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var data *models.UpdateJson
+	data, err = getUpdateJson(projectId, modId, loader, getUpdateOptions(c), c.Request.Context())
+
+	var version *models.Version
+	if data != nil {
+		if mcVersion != "" {
+			version = data.Versions[mcVersion+"-latest"]
+		} else {
+			version = getNewestVersion(data.Versions, nil)
+		}
+	}
+
+	if isBadRequest(err) {
+		d := map[string]string{"error": err.Error()}
+		cache.Set(cacheKey, http.StatusBadRequest, d)
+		c.JSON(http.StatusBadRequest, d)
+	} else if err != nil {
+		logger.Printf(c.Request.Context(), "Error: %s", err.Error())
+		d := map[string]string{"error": err.Error()}
+		cacheExpireTime := cache.Set(cacheKey, http.StatusInternalServerError, d)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.Status(http.StatusInternalServerError)
+	} else if version != nil {
+		metadata := models.ModMetadata{
+			ModId:           version.ModId,
+			Version:         version.Version,
+			DisplayName:     version.DisplayName,
+			Description:     version.Description,
+			Authors:         version.Authors,
+			License:         version.License,
+			LogoFile:        version.LogoFile,
+			DisplayUrl:      version.DisplayUrl,
+			IssueTrackerUrl: version.IssueTrackerUrl,
+			Side:            version.Side,
+			Url:             version.Url,
+		}
+		cacheExpireTime := cache.Set(cacheKey, http.StatusOK, metadata)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.JSON(http.StatusOK, metadata)
+	} else {
+		cacheExpireTime := cache.Set(cacheKey, http.StatusNotFound, nil)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.Status(http.StatusNotFound)
+	}
+}
+
+// getNewestVersion returns the most recently released version out of the promos that passes the filter
+func getNewestVersion(versions map[string]*models.Version, filter func(*models.Version) bool) *models.Version {
+	var newest *models.Version
+	for _, v := range versions {
+		if v == nil || (filter != nil && !filter(v)) {
+			continue
+		}
+		if newest == nil || v.ReleaseDate.After(newest.ReleaseDate) {
+			newest = v
+		}
+	}
+	return newest
+}
+
+// applyTomlMetadata copies the file-wide values of a mods.toml onto each mod, since those are stored per mod
+func applyTomlMetadata(modInfo *models.ModInfo) {
+	side := "BOTH"
+	if modInfo.ClientSideOnly {
+		side = "CLIENT"
+	}
+
+	for k, v := range modInfo.Mods {
+		v.Description = strings.TrimSpace(v.Description)
+		v.License = modInfo.License
+		v.IssueTrackerURL = modInfo.IssueTrackerURL
+		if v.LogoFile == "" {
+			v.LogoFile = modInfo.LogoFile
+		}
+		v.Side = side
+		modInfo.Mods[k] = v
+	}
+}
+
+// fabricMetadata flattens the fields of a fabric.mod.json that can come in several shapes into the plain values we store
+func fabricMetadata(fabric models.FabricMod) models.Mod {
+	mod := fabric.Mod
+
+	authors := make([]string, 0, len(fabric.Authors))
+	for _, v := range fabric.Authors {
+		if v.Name != "" {
+			authors = append(authors, v.Name)
+		}
+	}
+	mod.Authors = strings.Join(authors, ", ")
+	mod.License = strings.Join(fabric.License, ", ")
+	mod.LogoFile = readFabricIcon(fabric.Icon)
+	mod.DisplayURL = fabric.Contact["homepage"]
+	mod.IssueTrackerURL = fabric.Contact["issues"]
+	mod.Provides = strings.Join(fabric.Provides, ",")
+
+	switch strings.ToLower(fabric.Environment) {
+	case "client":
+		mod.Side = "CLIENT"
+	case "server":
+		mod.Side = "SERVER"
+	default:
+		mod.Side = "BOTH"
+	}
+
+	return mod
+}
+
+// readFabricIcon reads the icon, which is either a path or a map of sizes to paths. For the latter, the largest is used.
+func readFabricIcon(data json.RawMessage) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		return path
+	}
+
+	var sizes map[string]string
+	if err := json.Unmarshal(data, &sizes); err != nil {
+		return ""
+	}
+
+	largest := -1
+	for size, v := range sizes {
+		if s := cast.ToInt(size); s > largest {
+			largest = s
+			path = v
+		}
+	}
+	return path
+}
@@ -3,14 +3,77 @@ package models
 import "time"
 
 type Version struct {
-	Id           uint `gorm:"primaryKey;autoIncrement"`
-	CurseId      uint `gorm:"index"`
-	FileId       uint `gorm:"index"`
-	GameVersions string
-	ModId        string
-	Version      string
-	Type         int8 `gorm:"type:tinyint"`
-	ReleaseDate  time.Time
-	Url          string `gorm:"type:varchar(500)"`
-	Loader       string
+	Id              uint `gorm:"primaryKey;autoIncrement"`
+	CurseId         uint `gorm:"index"`
+	FileId          uint `gorm:"index"`
+	GameVersions    string
+	ModId           string
+	Version         string
+	Type            int8 `gorm:"type:tinyint"`
+	ReleaseDate     time.Time
+	Url             string `gorm:"type:varchar(500)"`
+	FileName        string
+	DownloadUrl     string `gorm:"type:varchar(500)"`
+	Loader          string
+	PackFormat      int
+	DisplayName     string
+	Description     string `gorm:"type:text"`
+	Authors         string `gorm:"type:varchar(500)"`
+	License         string
+	LogoFile        string
+	DisplayUrl      string `gorm:"type:varchar(500)"`
+	IssueTrackerUrl string `gorm:"type:varchar(500)"`
+	Side            string
+	McVersionRange  string
+	Provides        string
+	ParserVersion   int `gorm:"index"`
+}
+
+type VersionDependency struct {
+	Id           uint   `gorm:"primaryKey;autoIncrement" json:"-"`
+	VersionId    uint   `gorm:"index" json:"-"`
+	ModId        string `json:"modId"`
+	Type         string `json:"type"`
+	Mandatory    bool   `json:"mandatory"`
+	VersionRange string `json:"versionRange"`
+	Side         string `json:"side"`
+	Ordering     string `json:"ordering"`
+}
+
+// FileFailure tracks a file that could not be downloaded or read, so it is not retried on every request
+type FileFailure struct {
+	Id         uint      `gorm:"primaryKey;autoIncrement" json:"-"`
+	CurseId    uint      `gorm:"index" json:"-"`
+	FileId     uint      `gorm:"uniqueIndex" json:"fileId"`
+	ErrorClass string    `json:"errorClass"`
+	Message    string    `gorm:"type:varchar(500)" json:"message"`
+	Attempts   int       `json:"attempts"`
+	NextRetry  time.Time `json:"nextRetry"`
+	Permanent  bool      `json:"permanent"`
+}
+
+// CanRetry checks if enough time has passed since the last failure to try the file again
+func (f *FileFailure) CanRetry(now time.Time) bool {
+	return !f.Permanent && !now.Before(f.NextRetry)
+}
+
+// PromoSnapshot is the promos served for a mod and loader, stored each time they change
+type PromoSnapshot struct {
+	Id      uint   `gorm:"primaryKey;autoIncrement"`
+	CurseId uint   `gorm:"index:idx_promo_snapshot;uniqueIndex:idx_promo_snapshot_previous"`
+	ModId   string `gorm:"index:idx_promo_snapshot;uniqueIndex:idx_promo_snapshot_previous"`
+	Loader  string `gorm:"index:idx_promo_snapshot;uniqueIndex:idx_promo_snapshot_previous"`
+	//the snapshot this one replaced, 0 for the first. Only one snapshot can follow each, which stops two requests
+	//recording the same change. Snapshots from before this was added have none.
+	PreviousId *uint     `gorm:"uniqueIndex:idx_promo_snapshot_previous"`
+	Promos     string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+// PromoChange is a promo key which was added, changed or removed. From is empty for added keys, and To for removed ones.
+type PromoChange struct {
+	Key       string    `json:"key"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
 }
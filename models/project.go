@@ -1,29 +1,419 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type ModInfo struct {
-	Mods         []Mod
-	ModLoader    string
-	Dependencies map[string][]Dependency
+	Mods            []Mod
+	ModLoader       string
+	Dependencies    map[string][]Dependency
+	License         string
+	IssueTrackerURL string
+	LogoFile        string
+	ClientSideOnly  bool
 }
 
 type Mod struct {
-	ModId    string `json:"id"`
-	Version  string `json:"version"`
-	OldModId string `json:"modid"`
+	ModId           string `json:"id"`
+	Version         string `json:"version"`
+	OldModId        string `json:"modid"`
+	DisplayName     string `json:"name"`
+	Description     string `json:"description"`
+	Authors         string `json:"-"`
+	License         string `json:"-"`
+	LogoFile        string `json:"logoFile"`
+	DisplayURL      string `json:"url"`
+	IssueTrackerURL string `json:"-"`
+	Side            string `json:"-"`
+	Provides        string `json:"-"`
+	PackFormat      int    `json:"-"`
 }
 
 type Dependency struct {
-	ModId string
+	ModId        string `json:"modId"`
+	Mandatory    bool   `json:"mandatory"`
+	Type         string `json:"type"`
+	VersionRange string `json:"versionRange"`
+	Ordering     string `json:"ordering"`
+	Side         string `json:"side"`
+}
+
+type FabricMod struct {
+	Mod
+	Depends     map[string]StringList `json:"depends"`
+	Recommends  map[string]StringList `json:"recommends"`
+	Suggests    map[string]StringList `json:"suggests"`
+	Breaks      map[string]StringList `json:"breaks"`
+	Conflicts   map[string]StringList `json:"conflicts"`
+	Authors     []FabricPerson        `json:"authors"`
+	License     StringList            `json:"license"`
+	Icon        json.RawMessage       `json:"icon"`
+	Contact     map[string]string     `json:"contact"`
+	Environment string                `json:"environment"`
+	Provides    []string              `json:"provides"`
+}
+
+// FabricPerson is either just a name, or an object with the name and contact information
+type FabricPerson struct {
+	Name string `json:"name"`
+}
+
+func (p *FabricPerson) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		p.Name = name
+		return nil
+	}
+
+	var person struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &person); err != nil {
+		return err
+	}
+	p.Name = person.Name
+	return nil
+}
+
+// StringList is a list of strings in JSON that may also be given as a single string
+type StringList []string
+
+func (s *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = StringList{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+type QuiltMod struct {
+	QuiltLoader QuiltLoader `json:"quilt_loader"`
+	Minecraft   struct {
+		Environment string `json:"environment"`
+	} `json:"minecraft"`
+}
+
+type QuiltLoader struct {
+	Id       string           `json:"id"`
+	Version  string           `json:"version"`
+	Provides []QuiltReference `json:"provides"`
+	Depends  []QuiltReference `json:"depends"`
+	Breaks   []QuiltReference `json:"breaks"`
+	Metadata QuiltMetadata    `json:"metadata"`
+}
+
+type QuiltMetadata struct {
+	Name         string                     `json:"name"`
+	Description  string                     `json:"description"`
+	Contributors map[string]json.RawMessage `json:"contributors"`
+	Contact      map[string]string          `json:"contact"`
+	License      QuiltLicenses              `json:"license"`
+	Icon         json.RawMessage            `json:"icon"`
+}
+
+// QuiltReference is how quilt.mod.json points at another mod, used by provides, depends and breaks. It is either
+// just the mod id, an object with the id and details, or an array of those where any one of them will do.
+type QuiltReference struct {
+	Id       string        `json:"id"`
+	Version  string        `json:"version"`
+	Versions QuiltVersions `json:"versions"`
+	Optional bool          `json:"optional"`
+
+	//Alternatives holds every entry of the array form, the reference itself is the first of them
+	Alternatives []QuiltReference `json:"-"`
+}
+
+func (r *QuiltReference) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		*r = QuiltReference{Id: id}
+		return nil
+	}
+
+	var alternatives []QuiltReference
+	if err := json.Unmarshal(data, &alternatives); err == nil {
+		*r = QuiltReference{}
+		if len(alternatives) > 0 {
+			*r = alternatives[0]
+		}
+		r.Alternatives = alternatives
+		return nil
+	}
+
+	type reference QuiltReference
+	var full reference
+	if err := json.Unmarshal(data, &full); err != nil {
+		return err
+	}
+	*r = QuiltReference(full)
+	return nil
+}
+
+// References returns each mod the reference can be satisfied by
+func (r QuiltReference) References() []QuiltReference {
+	if r.Alternatives != nil {
+		return r.Alternatives
+	}
+	return []QuiltReference{r}
+}
+
+// QuiltVersions is a version constraint, which is a single predicate, a list of predicates where any may match, or an
+// object of "any" or "all" lists. It is kept in the same form as Fabric ranges, with "||" between alternatives.
+type QuiltVersions string
+
+func (v *QuiltVersions) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*v = QuiltVersions(single)
+		return nil
+	}
+
+	var list []QuiltVersions
+	if err := json.Unmarshal(data, &list); err == nil {
+		*v = joinQuiltVersions(list, " || ")
+		return nil
+	}
+
+	var object struct {
+		Any []QuiltVersions `json:"any"`
+		All []QuiltVersions `json:"all"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	if len(object.All) > 0 {
+		*v = joinQuiltVersions(object.All, " ")
+	} else {
+		*v = joinQuiltVersions(object.Any, " || ")
+	}
+	return nil
+}
+
+func joinQuiltVersions(list []QuiltVersions, separator string) QuiltVersions {
+	result := ""
+	for k, z := range list {
+		if k > 0 {
+			result += separator
+		}
+		result += string(z)
+	}
+	return QuiltVersions(result)
+}
+
+// QuiltLicenses is the license of a mod, which is either an SPDX id, an object describing the license, or a list of
+// either
+type QuiltLicenses []string
+
+func (l *QuiltLicenses) UnmarshalJSON(data []byte) error {
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		list = []json.RawMessage{data}
+	}
+
+	result := make(QuiltLicenses, 0, len(list))
+	for _, v := range list {
+		var id string
+		if err := json.Unmarshal(v, &id); err == nil {
+			result = append(result, id)
+			continue
+		}
+
+		var license struct {
+			Id   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(v, &license); err != nil {
+			return err
+		}
+		if license.Id != "" {
+			result = append(result, license.Id)
+		} else {
+			result = append(result, license.Name)
+		}
+	}
+	*l = result
+	return nil
 }
 
 type McMod struct {
-	ModList []Mod
+	ModList []LegacyMod
+}
+
+type LegacyMod struct {
+	Mod
+	AuthorList []string `json:"authorList"`
+}
+
+type PackMeta struct {
+	Pack Pack `json:"pack"`
+}
+
+type Pack struct {
+	PackFormat  int             `json:"pack_format"`
+	Description json.RawMessage `json:"description"`
+	Version     string          `json:"version"`
 }
 
 type UpdateJson struct {
-	Promos     map[string]string `json:"promos"`
-	References References        `json:"-"`
-	HomePage   string            `json:"homepage"`
+	Promos      map[string]string   `json:"promos"`
+	References  References          `json:"-"`
+	Versions    map[string]*Version `json:"-"`
+	HomePage    string              `json:"homepage"`
+	Diagnostics *Diagnostics        `json:"diagnostics,omitempty"`
+}
+
+// ExtendedUpdateJson is the update JSON along with the file behind each promo key
+type ExtendedUpdateJson struct {
+	Promos      map[string]string    `json:"promos"`
+	Files       map[string]PromoFile `json:"files"`
+	HomePage    string               `json:"homepage"`
+	Diagnostics *Diagnostics         `json:"diagnostics,omitempty"`
+}
+
+type PromoFile struct {
+	Version     string    `json:"version"`
+	FileId      uint      `json:"fileId"`
+	FileName    string    `json:"fileName"`
+	ReleaseType string    `json:"releaseType"`
+	ReleaseDate time.Time `json:"releaseDate"`
+	Url         string    `json:"url"`
+	DownloadUrl string    `json:"downloadUrl,omitempty"`
+}
+
+type Diagnostics struct {
+	RangeMismatches []RangeMismatch `json:"rangeMismatches"`
+}
+
+// RangeMismatch lists the differences between the Minecraft versions a file declares support for and the versions
+// it is tagged with on CurseForge
+type RangeMismatch struct {
+	FileId        uint     `json:"fileId"`
+	Version       string   `json:"version"`
+	DeclaredRange string   `json:"declaredRange"`
+	Untagged      []string `json:"untagged,omitempty"`
+	OutOfRange    []string `json:"outOfRange,omitempty"`
+}
+
+// ProjectIndex lists the mods found in a project, with the update URL of each mod and loader
+type ProjectIndex struct {
+	ProjectId uint         `json:"projectId"`
+	HomePage  string       `json:"homepage"`
+	Mods      []IndexedMod `json:"mods"`
+}
+
+type IndexedMod struct {
+	ModId        string            `json:"modId"`
+	Loaders      []string          `json:"loaders"`
+	GameVersions []string          `json:"gameVersions"`
+	UpdateUrls   map[string]string `json:"updateUrls"`
+}
+
+// VersionList is one page of the versions of a mod, newest first
+type VersionList struct {
+	Versions []VersionEntry `json:"versions"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"pageSize"`
+	Total    int            `json:"total"`
+}
+
+type VersionEntry struct {
+	Version      string    `json:"version"`
+	FileId       uint      `json:"fileId"`
+	FileName     string    `json:"fileName"`
+	GameVersions []string  `json:"gameVersions"`
+	Loaders      []string  `json:"loaders"`
+	ReleaseType  string    `json:"releaseType"`
+	ReleaseDate  time.Time `json:"releaseDate"`
+	Url          string    `json:"url"`
+	DownloadUrl  string    `json:"downloadUrl,omitempty"`
+}
+
+// VersionCheck is what Forge's update checker would tell a client running the given version
+type VersionCheck struct {
+	Status   string `json:"status"`
+	Target   string `json:"target,omitempty"`
+	HomePage string `json:"homepage"`
+}
+
+type ModMetadata struct {
+	ModId           string `json:"modId"`
+	Version         string `json:"version"`
+	DisplayName     string `json:"displayName"`
+	Description     string `json:"description"`
+	Authors         string `json:"authors"`
+	License         string `json:"license"`
+	LogoFile        string `json:"logoFile"`
+	DisplayUrl      string `json:"displayUrl"`
+	IssueTrackerUrl string `json:"issueTrackerUrl"`
+	Side            string `json:"side"`
+	Url             string `json:"url"`
+}
+
+type DependencyInfo struct {
+	Version      string              `json:"version"`
+	Url          string              `json:"url"`
+	Dependencies []VersionDependency `json:"dependencies"`
+}
+
+// DebugReport explains for every file of a project why it is or isn't used in the promos
+type DebugReport struct {
+	Promos map[string]string `json:"promos"`
+	Files  []FileDecision    `json:"files"`
+}
+
+type FileDecision struct {
+	FileId              uint         `json:"fileId"`
+	ModId               string       `json:"modId"`
+	Version             string       `json:"version"`
+	Loader              string       `json:"loader"`
+	GameVersions        []string     `json:"gameVersions"`
+	Decision            string       `json:"decision"`
+	Reason              string       `json:"reason,omitempty"`
+	DroppedGameVersions []string     `json:"droppedGameVersions,omitempty"`
+	Won                 []string     `json:"won,omitempty"`
+	Lost                []LostKey    `json:"lost,omitempty"`
+	Failure             *FileFailure `json:"failure,omitempty"`
+}
+
+// LostKey is a promo key a file competed for, and the file that took it instead
+type LostKey struct {
+	Key     string `json:"key"`
+	FileId  uint   `json:"fileId"`
+	ModId   string `json:"modId"`
+	Version string `json:"version"`
+}
+
+// InspectResult is what was read from an uploaded jar, before any CurseForge data is applied
+type InspectResult struct {
+	Mods            []InspectedMod `json:"mods"`
+	Loaders         []string       `json:"loaders"`
+	ManifestVersion string         `json:"manifestVersion"`
+	Errors          []EntryError   `json:"errors"`
+}
+
+type InspectedMod struct {
+	ModId           string       `json:"modId"`
+	Version         string       `json:"version"`
+	DeclaredVersion string       `json:"declaredVersion"`
+	DisplayName     string       `json:"displayName"`
+	Side            string       `json:"side"`
+	Provides        []string     `json:"provides"`
+	McVersionRange  string       `json:"mcVersionRange"`
+	Dependencies    []Dependency `json:"dependencies"`
+}
+
+type EntryError struct {
+	Entry string `json:"entry"`
+	Error string `json:"error"`
 }
 
 type References map[string]string
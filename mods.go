@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/cfwidget/updatejson/cache"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+// getProjectPromos returns the promos for every mod id found in the project's files, keyed by mod id and then loader
+func getProjectPromos(c *gin.Context) {
+	pid := c.Param("projectId")
+
+	cacheKey := cache.GetKey(c)
+
+	var projectId uint
+	var err error
+	if projectId, err = cast.ToUintE(pid); err != nil {
+		//this route catches every other path, so caching these would fill the cache with whatever is asked for
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	opts := getUpdateOptions(c)
+	var data map[string]map[string]*models.UpdateJson
+	data, err = getProjectUpdateJson(projectId, opts, c.Request.Context())
+
+	if isBadRequest(err) {
+		d := map[string]string{"error": err.Error()}
+		cache.Set(cacheKey, http.StatusBadRequest, d)
+		c.JSON(http.StatusBadRequest, d)
+	} else if err != nil {
+		logger.Printf(c.Request.Context(), "Error: %s", err.Error())
+		d := map[string]string{"error": err.Error()}
+		cacheExpireTime := cache.Set(cacheKey, http.StatusInternalServerError, d)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.Status(http.StatusInternalServerError)
+	} else {
+		response := make(map[string]map[string]any, len(data))
+		for modId, v := range data {
+			response[modId] = allLoadersResponse(v, opts)
+		}
+		cacheExpireTime := cache.Set(cacheKey, http.StatusOK, response)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// getProjectUpdateJson builds the promos of every loader of every mod id found in the project's files
+func getProjectUpdateJson(projectId uint, opts UpdateOptions, ctx context.Context) (map[string]map[string]*models.UpdateJson, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	project, versionMap, _, err := getProjectVersions(projectId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string]*models.UpdateJson)
+	for _, modId := range getModIds(versionMap) {
+		result[modId] = getAllPromos(project, versionMap, modId, opts, ctx)
+	}
+	return result, nil
+}
+
+// getModIds lists the mod ids found in the files which can be promoted
+func getModIds(versionMap map[VersionKey]*models.Version) []string {
+	modIds := make([]string, 0)
+	for _, v := range versionMap {
+		if v.ModId == "" || v.Version == "" || slices.Contains(modIds, v.ModId) {
+			continue
+		}
+		modIds = append(modIds, v.ModId)
+	}
+	slices.Sort(modIds)
+	return modIds
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/cfwidget/updatejson/config"
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/spf13/cast"
+)
+
+const resourcePackLoader = "resourcepack"
+const dataPackLoader = "datapack"
+
+// isPackLoader checks if the loader is one of the pack types, which are not mod loaders at all
+func isPackLoader(loader string) bool {
+	return loader == resourcePackLoader || loader == dataPackLoader
+}
+
+var packVersionRegex = regexp.MustCompile(config.Default().Packs.VersionPattern)
+var packVersionSource = config.Default().Packs.VersionSource
+
+// parsePackFile reads the pack.mcmeta from a resource or data pack and turns it into a single "mod" entry, using the
+// project slug as the id so promos can be requested for it like any other mod
+func parsePackFile(file *zip.Reader, project curseforge.Project, curseFile curseforge.File, ctx context.Context) (*models.ModInfo, error) {
+	meta, err := findPackMeta(file, limits.NestingDepth)
+	if errors.Is(err, ErrArchiveLimit) {
+		return nil, err
+	}
+	if err != nil {
+		logger.Printf(ctx, "Failed to parse pack.mcmeta: %s", err)
+		return nil, nil
+	}
+	if meta == nil {
+		return nil, nil
+	}
+
+	loader := resourcePackLoader
+	if project.ClassId == curseforge.ClassDataPacks {
+		loader = dataPackLoader
+	}
+
+	return &models.ModInfo{
+		Mods: []models.Mod{{
+			ModId:       getPackId(project),
+			Version:     getPackVersion(meta, curseFile),
+			Description: readPackDescription(meta.Pack.Description),
+			PackFormat:  meta.Pack.PackFormat,
+		}},
+		ModLoader: loader,
+	}, nil
+}
+
+// findPackMeta looks for pack.mcmeta at the root of the archive. Data packs are commonly uploaded as a zip holding
+// the actual pack zip, so nested zips are checked as well, up to the given depth
+func findPackMeta(file *zip.Reader, depth int) (*models.PackMeta, error) {
+	if err := checkArchive(file); err != nil {
+		return nil, err
+	}
+
+	for _, f := range file.File {
+		if f.Name != "pack.mcmeta" {
+			continue
+		}
+
+		data, err := readZipEntry(f)
+		if err != nil {
+			return nil, err
+		}
+
+		meta := &models.PackMeta{}
+		err = json.Unmarshal(data, meta)
+		if err != nil {
+			return nil, err
+		}
+		return meta, nil
+	}
+
+	if depth <= 0 {
+		return nil, nil
+	}
+
+	for _, f := range file.File {
+		if f.FileInfo().IsDir() || !strings.HasSuffix(strings.ToLower(f.Name), ".zip") {
+			continue
+		}
+
+		data, err := readZipEntry(f)
+		if err != nil {
+			return nil, err
+		}
+
+		nested, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			continue
+		}
+
+		meta, err := findPackMeta(nested, depth-1)
+		if err != nil || meta != nil {
+			return meta, err
+		}
+	}
+
+	return nil, nil
+}
+
+// getPackVersion pulls the version either from the optional version field in pack.mcmeta or from the file's display
+// name, depending on PACK_VERSION_SOURCE. The display name is used whenever the preferred source has nothing.
+func getPackVersion(meta *models.PackMeta, curseFile curseforge.File) string {
+	if packVersionSource == "mcmeta" && meta.Pack.Version != "" {
+		return meta.Pack.Version
+	}
+
+	for _, v := range []string{curseFile.DisplayName, strings.TrimSuffix(curseFile.FileName, ".zip")} {
+		match := packVersionRegex.FindStringSubmatch(v)
+		if len(match) > 1 {
+			return match[1]
+		}
+		if len(match) == 1 {
+			return match[0]
+		}
+	}
+
+	return meta.Pack.Version
+}
+
+func getPackId(project curseforge.Project) string {
+	if project.Slug != "" {
+		return project.Slug
+	}
+	return cast.ToString(project.Id)
+}
+
+// readPackDescription flattens the description, which may be a plain string or a text component
+func readPackDescription(data json.RawMessage) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	var component any
+	if err := json.Unmarshal(data, &component); err != nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	flattenTextComponent(component, &sb)
+	return sb.String()
+}
+
+func flattenTextComponent(component any, sb *strings.Builder) {
+	switch v := component.(type) {
+	case string:
+		sb.WriteString(v)
+	case []any:
+		for _, z := range v {
+			flattenTextComponent(z, sb)
+		}
+	case map[string]any:
+		if text, ok := v["text"].(string); ok {
+			sb.WriteString(text)
+		}
+		if extra, ok := v["extra"]; ok {
+			flattenTextComponent(extra, sb)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+(function () {
+    var form = document.getElementById('picker');
+    if (!form) return;
+
+    var input = document.getElementById('picker-project');
+    var status = document.getElementById('picker-status');
+    var results = document.getElementById('picker-results');
+
+    function show(message) {
+        status.textContent = message;
+        results.innerHTML = '';
+    }
+
+    form.addEventListener('submit', function (event) {
+        event.preventDefault();
+
+        var project = input.value.trim().toLowerCase();
+        //accept a full CurseForge link to a mod or pack as well as an id or slug
+        var match = project.match(/\/(?:mc-mods|texture-packs|data-packs)\/([a-z0-9-]+)/);
+        if (match) project = match[1];
+        if (!project) return;
+
+        show('Looking up ' + project + '...');
+        fetch('/api/projects/' + encodeURIComponent(project))
+            .then(function (response) {
+                return response.json().then(function (data) {
+                    if (!response.ok) throw new Error(data && data.error ? data.error : 'Project could not be found');
+                    return data;
+                });
+            })
+            .then(function (index) {
+                if (index.mods.length === 0) {
+                    show('No mods were found in project ' + index.projectId + '.');
+                    return;
+                }
+
+                show('Project ' + index.projectId + ' contains ' + index.mods.length + ' mod(s):');
+                index.mods.forEach(function (mod) {
+                    var item = document.createElement('li');
+                    item.className = 'mb3';
+
+                    var title = document.createElement('div');
+                    title.className = 'b';
+                    title.textContent = mod.modId;
+                    item.appendChild(title);
+
+                    if (mod.gameVersions.length > 0) {
+                        var versions = document.createElement('div');
+                        versions.className = 'f6 black-60';
+                        versions.textContent = 'Minecraft ' + mod.gameVersions.join(', ');
+                        item.appendChild(versions);
+                    }
+
+                    mod.loaders.forEach(function (loader) {
+                        var url = document.createElement('code');
+                        url.className = 'db roboto-mono f6';
+                        url.style.wordBreak = 'break-all';
+                        url.textContent = loader + ': ' + mod.updateUrls[loader];
+                        item.appendChild(url);
+                    });
+
+                    results.appendChild(item);
+                });
+            })
+            .catch(function (error) {
+                show(error.message);
+            });
+    });
+})();
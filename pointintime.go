@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+var ErrInvalidTime = errors.New("invalid time, must be RFC3339 such as 2024-01-02T15:04:05Z")
+
+// servePointInTime answers requests asking for promos as they were at a given time. These are built only from what is
+// stored, so they skip the cache and CurseForge entirely. Requests without a time are passed on.
+func servePointInTime(response func(*models.UpdateJson, UpdateOptions) any) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		at := c.Query("at")
+		if at == "" {
+			return
+		}
+		c.Abort()
+		c.Header("Cache-Control", "no-store")
+
+		pid := c.Param("projectId")
+		modId := c.Param("modId")
+		loader := getLoader(c)
+		ctx := c.Request.Context()
+
+		projectId, err := cast.ToUintE(pid)
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		opts := getUpdateOptions(c)
+		if opts.At, err = time.Parse(time.RFC3339, at); err != nil {
+			c.JSON(http.StatusBadRequest, map[string]string{"error": ErrInvalidTime.Error()})
+			return
+		}
+
+		data, err := getStoredUpdateJson(projectId, modId, loader, opts, ctx)
+		if isBadRequest(err) {
+			c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else if err != nil {
+			logger.Printf(ctx, "Error: %s", err.Error())
+			c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		} else if data != nil {
+			c.JSON(http.StatusOK, response(data, opts))
+		} else {
+			c.Status(http.StatusNotFound)
+		}
+	}
+}
+
+// getStoredUpdateJson builds the promos from the stored versions which were released before opts.At, returning nil if
+// nothing was released by then
+func getStoredUpdateJson(projectId uint, modId string, loader string, opts UpdateOptions, ctx context.Context) (*models.UpdateJson, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	versionMap, err := getStoredVersions(projectId, ctx)
+	if err != nil {
+		return nil, err
+	}
+	//only what had been released by then
+	maps.DeleteFunc(versionMap, func(_ VersionKey, v *models.Version) bool {
+		return !v.ReleaseDate.Before(opts.At)
+	})
+	if len(versionMap) == 0 {
+		return nil, nil
+	}
+
+	project := curseforge.Project{Id: projectId, Links: curseforge.Links{WebsiteUrl: getProjectPage(versionMap)}}
+
+	return getPromos(project, versionMap, modId, loader, opts, ctx), nil
+}
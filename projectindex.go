@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cfwidget/updatejson/cache"
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/database"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/cfwidget/updatejson/util"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+var slugRegex = regexp.MustCompile("^[a-z0-9-]+$")
+
+// getProjectIndex lists the mods of a project, found by id or slug, with the URL to use for each mod and loader
+func getProjectIndex(c *gin.Context) {
+	project := strings.ToLower(c.Param("project"))
+
+	cacheKey := cache.GetKey(c)
+
+	data, err := buildProjectIndex(project, getBaseUrl(c), c.Request.Context())
+
+	if isBadRequest(err) {
+		d := map[string]string{"error": err.Error()}
+		cache.Set(cacheKey, http.StatusBadRequest, d)
+		c.JSON(http.StatusBadRequest, d)
+	} else if err != nil {
+		logger.Printf(c.Request.Context(), "Error: %s", err.Error())
+		d := map[string]string{"error": err.Error()}
+		cacheExpireTime := cache.Set(cacheKey, http.StatusInternalServerError, d)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.Status(http.StatusInternalServerError)
+	} else {
+		cacheExpireTime := cache.Set(cacheKey, http.StatusOK, data)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.JSON(http.StatusOK, data)
+	}
+}
+
+// buildProjectIndex lists the mods of the project by id or slug. What we have stored is used if we have found mods in
+// the project before, otherwise it is read from CurseForge.
+func buildProjectIndex(project string, baseUrl string, ctx context.Context) (*models.ProjectIndex, error) {
+	projectId, err := cast.ToUintE(project)
+	if err != nil {
+		if !slugRegex.MatchString(project) {
+			return nil, curseforge.ErrInvalidProjectId
+		}
+		projectId, err = findProjectBySlug(project, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	versionMap, err := getStoredVersions(projectId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	//files we couldn't find a mod in don't tell us anything, so the project is read again
+	curseProject := curseforge.Project{Id: projectId}
+	if len(getModIds(versionMap)) == 0 {
+		curseProject, versionMap, _, err = getProjectVersions(projectId, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index := &models.ProjectIndex{
+		ProjectId: projectId,
+		HomePage:  curseProject.Links.WebsiteUrl,
+		Mods:      make([]models.IndexedMod, 0),
+	}
+	if index.HomePage == "" {
+		index.HomePage = getProjectPage(versionMap)
+	}
+
+	for _, modId := range getModIds(versionMap) {
+		mod := models.IndexedMod{
+			ModId:        modId,
+			Loaders:      getModLoaders(versionMap, modId),
+			GameVersions: getModGameVersions(versionMap, modId),
+			UpdateUrls:   make(map[string]string),
+		}
+		for _, loader := range mod.Loaders {
+			//packs are served whatever the loader, so they need no ml
+			if isPackLoader(loader) {
+				mod.UpdateUrls[loader] = fmt.Sprintf("%s/%d/%s", baseUrl, projectId, modId)
+			} else {
+				mod.UpdateUrls[loader] = fmt.Sprintf("%s/%d/%s?ml=%s", baseUrl, projectId, modId, loader)
+			}
+		}
+		index.Mods = append(index.Mods, mod)
+	}
+
+	return index, nil
+}
+
+// findProjectBySlug looks for the slug in the file urls we have stored, and asks CurseForge if we have none
+func findProjectBySlug(slug string, ctx context.Context) (uint, error) {
+	db, err := database.Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var version models.Version
+	err = db.Where("url LIKE ?", "%/"+slug+"/files/%").Limit(1).Find(&version).Error
+	if err != nil {
+		return 0, err
+	}
+	if version.CurseId != 0 {
+		return version.CurseId, nil
+	}
+
+	project, err := curseforge.GetProjectBySlug(slug, ctx)
+	if err != nil {
+		return 0, err
+	}
+	return project.Id, nil
+}
+
+// getStoredVersions loads every mod we have stored for the project
+func getStoredVersions(projectId uint, ctx context.Context) (map[VersionKey]*models.Version, error) {
+	db, err := database.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*models.Version
+	err = db.Where(&models.Version{CurseId: projectId}).Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return newVersionMap(versions...), nil
+}
+
+// getModGameVersions lists the Minecraft versions the mod has files for, newest first
+func getModGameVersions(versionMap map[VersionKey]*models.Version, modId string) []string {
+	gameVersions := make([]string, 0)
+	for _, v := range versionMap {
+		if v.Version == "" || !matchesModId(v, modId) {
+			continue
+		}
+		for _, gameVersion := range strings.Split(v.GameVersions, ",") {
+			if gameVersion != "" && !invalidGameVersionRegex.MatchString(gameVersion) && !slices.Contains(gameVersions, gameVersion) {
+				gameVersions = append(gameVersions, gameVersion)
+			}
+		}
+	}
+	slices.SortFunc(gameVersions, func(a, b string) int { return util.CompareVersions(b, a) })
+	return gameVersions
+}
+
+// getProjectPage works out the CurseForge page of the project from the page of one of its files
+func getProjectPage(versionMap map[VersionKey]*models.Version) string {
+	for _, v := range versionMap {
+		//file urls are the project page followed by /files/<id>
+		if i := strings.LastIndex(v.Url, "/files/"); i > 0 {
+			return v.Url[:i]
+		}
+	}
+	return ""
+}
+
+// getBaseUrl returns the address clients should use to reach the service
+func getBaseUrl(c *gin.Context) string {
+	if host != "" {
+		return "https://" + host
+	}
+	if c.Request.TLS != nil {
+		return "https://" + c.Request.Host
+	}
+	return "http://" + c.Request.Host
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cfwidget/updatejson/config"
+	"github.com/cfwidget/updatejson/models"
+)
+
+// CurseForge release types, where a lower number is more stable
+const (
+	releaseTypeRelease int8 = 1
+	releaseTypeBeta    int8 = 2
+	releaseTypeAlpha   int8 = 3
+)
+
+var promotion = config.Default().Promotion
+
+// getReleaseType converts the name of a release type to the CurseForge number, treating anything unknown as alpha
+func getReleaseType(name string) int8 {
+	if i := slices.Index(config.ReleaseTypes, strings.ToLower(name)); i >= 0 {
+		return int8(i + 1)
+	}
+	return releaseTypeAlpha
+}
+
+// promoter picks the version for each promo key according to a promotion policy
+type promoter struct {
+	policy     config.PromotionPolicy
+	latestType int8
+	now        time.Time
+	trace      *promoTrace
+	//also pick the newest version of each of these channels
+	channelKeys []int8
+	//the channel that was asked for, which pinned versions must also be in
+	channel int8
+
+	results  map[string]*models.Version
+	fallback map[string]*models.Version
+}
+
+func newPromoter(policy config.PromotionPolicy, now time.Time, trace *promoTrace) *promoter {
+	return &promoter{
+		policy:     policy,
+		latestType: getReleaseType(policy.Latest),
+		now:        now,
+		trace:      trace,
+		results:    make(map[string]*models.Version),
+		fallback:   make(map[string]*models.Version),
+	}
+}
+
+// add considers the version for the promos of a single Minecraft version
+func (p *promoter) add(v *models.Version, gameVersion string) {
+	if v.Type <= p.latestType {
+		p.compete(p.results, v, gameVersion+"-latest")
+	}
+	for _, channel := range p.channelKeys {
+		if inChannel(v, channel) {
+			p.compete(p.results, v, gameVersion+"-"+getReleaseTypeName(channel))
+		}
+	}
+
+	delay := time.Duration(p.policy.RecommendedDelay)
+	if delay > 0 && p.now.Sub(v.ReleaseDate) < delay {
+		return
+	}
+	if v.Type == releaseTypeRelease {
+		p.compete(p.results, v, gameVersion+"-recommended")
+	} else if v.Type == releaseTypeBeta && p.policy.BetaFallback {
+		p.compete(p.fallback, v, gameVersion+"-recommended")
+	}
+}
+
+func (p *promoter) compete(results map[string]*models.Version, v *models.Version, key string) {
+	p.trace.compete(v, key)
+	existing, exists := results[key]
+	if !exists || v.ReleaseDate.After(existing.ReleaseDate) {
+		results[key] = v
+	}
+}
+
+// finish fills in keys with no release from the betas, then applies the pinned versions that are in the requested
+// channel and tagged for the key's Minecraft version
+func (p *promoter) finish(versionMap map[VersionKey]*models.Version, modId string, loader string) map[string]*models.Version {
+	for k, v := range p.fallback {
+		if _, exists := p.results[k]; !exists {
+			p.results[k] = v
+		}
+	}
+
+	for key, pinned := range p.policy.Pinned {
+		gameVersion := key[:strings.LastIndex(key, "-")]
+		var match *models.Version
+		for _, v := range versionMap {
+			if v.Version != pinned || getSkipReason(v, modId, loader) != "" {
+				continue
+			}
+			if !inChannel(v, p.channel) || !slices.Contains(strings.Split(v.GameVersions, ","), gameVersion) {
+				continue
+			}
+			if match == nil || v.ReleaseDate.After(match.ReleaseDate) {
+				match = v
+			}
+		}
+		if match != nil {
+			p.trace.compete(match, key)
+			p.results[key] = match
+		}
+	}
+
+	return p.results
+}
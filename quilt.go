@@ -0,0 +1,104 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/cfwidget/updatejson/models"
+)
+
+// quiltMetadata reads the mod out of a quilt.mod.json, where everything lives under quilt_loader
+func quiltMetadata(quilt models.QuiltMod) models.Mod {
+	loader := quilt.QuiltLoader
+
+	authors := make([]string, 0, len(loader.Metadata.Contributors))
+	for name := range loader.Metadata.Contributors {
+		authors = append(authors, name)
+	}
+	slices.Sort(authors)
+
+	provides := make([]string, 0, len(loader.Provides))
+	for _, ref := range loader.Provides {
+		for _, v := range ref.References() {
+			if v.Id != "" {
+				provides = append(provides, v.Id)
+			}
+		}
+	}
+
+	mod := models.Mod{
+		ModId:           loader.Id,
+		Version:         loader.Version,
+		DisplayName:     loader.Metadata.Name,
+		Description:     strings.TrimSpace(loader.Metadata.Description),
+		Authors:         strings.Join(authors, ", "),
+		License:         strings.Join(loader.Metadata.License, ", "),
+		LogoFile:        readFabricIcon(loader.Metadata.Icon),
+		DisplayURL:      loader.Metadata.Contact["homepage"],
+		IssueTrackerURL: loader.Metadata.Contact["issues"],
+		Provides:        strings.Join(provides, ","),
+		Side:            "BOTH",
+	}
+
+	switch strings.ToLower(quilt.Minecraft.Environment) {
+	case "client":
+		mod.Side = "CLIENT"
+	case "dedicated_server":
+		mod.Side = "SERVER"
+	}
+
+	return mod
+}
+
+// quiltDependencies converts the depends and breaks lists of a quilt.mod.json into dependencies
+func quiltDependencies(quilt models.QuiltMod) []models.Dependency {
+	deps := make([]models.Dependency, 0)
+
+	for _, ref := range quilt.QuiltLoader.Depends {
+		//when any of several mods will do, none of them is required on its own
+		anyOf := len(ref.References()) > 1
+		for _, v := range ref.References() {
+			if v.Id == "" {
+				continue
+			}
+			optional := v.Optional || anyOf
+			dep := models.Dependency{
+				ModId:        v.Id,
+				Mandatory:    !optional,
+				Type:         "required",
+				VersionRange: string(v.Versions),
+				Ordering:     "NONE",
+				Side:         "BOTH",
+			}
+			if optional {
+				dep.Type = "optional"
+			}
+			deps = append(deps, dep)
+		}
+	}
+
+	for _, ref := range quilt.QuiltLoader.Breaks {
+		for _, v := range ref.References() {
+			if v.Id == "" {
+				continue
+			}
+			deps = append(deps, models.Dependency{
+				ModId:        v.Id,
+				Type:         "incompatible",
+				VersionRange: string(v.Versions),
+				Ordering:     "NONE",
+				Side:         "BOTH",
+			})
+		}
+	}
+
+	return deps
+}
+
+// matchesModId checks if the version is the requested mod, either by its own id or one it provides
+func matchesModId(version *models.Version, modId string) bool {
+	if version.ModId == modId {
+		return true
+	}
+	return version.Provides != "" && slices.Contains(strings.Split(version.Provides, ","), modId)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/cfwidget/updatejson/util"
+)
+
+// projects which always have their promos expanded to every MC version in the declared range
+var expandRangeProjects []uint
+
+func shouldExpandRanges(projectId uint, opts UpdateOptions) bool {
+	return opts.ExpandRanges || slices.Contains(expandRangeProjects, projectId)
+}
+
+// getMinecraftRange finds the version range a mod declares for Minecraft itself
+func getMinecraftRange(deps []models.Dependency) string {
+	for _, v := range deps {
+		if v.ModId == "minecraft" && v.Type != "incompatible" && v.Type != "discouraged" {
+			return v.VersionRange
+		}
+	}
+	return ""
+}
+
+// getKnownMinecraftVersions returns the release versions of Minecraft we can expand ranges into. If CurseForge can't
+// tell us, the versions the project's own files are tagged with are used instead.
+func getKnownMinecraftVersions(versionMap map[VersionKey]*models.Version, ctx context.Context) []string {
+	known, err := curseforge.GetMinecraftVersions(ctx)
+	if err != nil {
+		logger.Printf(ctx, "Failed to get Minecraft versions, using project versions: %s", err.Error())
+		return getProjectMinecraftVersions(versionMap)
+	}
+	return filterReleaseVersions(known)
+}
+
+// getProjectMinecraftVersions returns the release versions of Minecraft the project's files are tagged with
+func getProjectMinecraftVersions(versionMap map[VersionKey]*models.Version) []string {
+	known := make([]string, 0)
+	for _, v := range versionMap {
+		known = append(known, strings.Split(v.GameVersions, ",")...)
+	}
+	return filterReleaseVersions(known)
+}
+
+func filterReleaseVersions(versions []string) []string {
+	result := make([]string, 0, len(versions))
+	for _, v := range util.Dedup(versions) {
+		if v != "" && !invalidGameVersionRegex.MatchString(v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// getGameVersions returns the versions a file is tagged with, plus every known version inside its declared range
+// when known versions are given
+func getGameVersions(version *models.Version, knownVersions []string) []string {
+	tagged := strings.Split(version.GameVersions, ",")
+	if knownVersions == nil || version.McVersionRange == "" {
+		return tagged
+	}
+
+	//an unbounded range would put the file on every version, including ones it can't have been tested on
+	declared, err := util.ParseVersionRange(version.McVersionRange, version.Loader)
+	if err != nil || !declared.IsBounded() {
+		return tagged
+	}
+
+	result := slices.Clone(tagged)
+	for _, v := range knownVersions {
+		if declared.Contains(v) && !slices.Contains(result, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// getRangeMismatch compares the declared range of a file against its CurseForge tags, returning nil if they agree
+func getRangeMismatch(version *models.Version, knownVersions []string) *models.RangeMismatch {
+	if version.McVersionRange == "" {
+		return nil
+	}
+
+	declared, err := util.ParseVersionRange(version.McVersionRange, version.Loader)
+	if err != nil {
+		return nil
+	}
+
+	tagged := make([]string, 0)
+	for v := range strings.SplitSeq(version.GameVersions, ",") {
+		if v != "" && !invalidGameVersionRegex.MatchString(v) {
+			tagged = append(tagged, v)
+		}
+	}
+
+	mismatch := &models.RangeMismatch{
+		FileId:        version.FileId,
+		Version:       version.Version,
+		DeclaredRange: version.McVersionRange,
+	}
+	for _, v := range tagged {
+		if !declared.Contains(v) {
+			mismatch.OutOfRange = append(mismatch.OutOfRange, v)
+		}
+	}
+	//versions are only missing tags if the range would have been expanded to them
+	if declared.IsBounded() {
+		for _, v := range knownVersions {
+			if declared.Contains(v) && !slices.Contains(tagged, v) {
+				mismatch.Untagged = append(mismatch.Untagged, v)
+			}
+		}
+	}
+
+	if len(mismatch.OutOfRange) == 0 && len(mismatch.Untagged) == 0 {
+		return nil
+	}
+	return mismatch
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/cfwidget/updatejson/config"
+	"github.com/cfwidget/updatejson/curseforge"
+	"github.com/cfwidget/updatejson/database"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"gorm.io/gorm"
+)
+
+// parserVersion is stored on every row, and must be increased whenever parsing changes in a way that should be
+// applied to files which are already indexed. Outdated rows are parsed again as they are requested.
+const parserVersion = 1
+
+var metricReparsed = expvar.NewInt("files_reparsed")
+
+// limits how many files are parsed again while serving requests, so a parser update doesn't download everything at once
+var lazyReparses = &throttle{limit: config.Default().Reparse.PerMinute, interval: time.Minute}
+
+type throttle struct {
+	limit    int
+	interval time.Duration
+
+	lock   sync.Mutex
+	window time.Time
+	count  int
+}
+
+func (t *throttle) Allow(now time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if now.Sub(t.window) >= t.interval {
+		t.window = now
+		t.count = 0
+	}
+	if t.count >= t.limit {
+		return false
+	}
+	t.count++
+	return true
+}
+
+func isOutdated(versions []*models.Version) bool {
+	for _, v := range versions {
+		if v.ParserVersion < parserVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// tryReparse parses a file again with the current parser, unless it failed recently. The new rows replace the old ones.
+func tryReparse(db *gorm.DB, project curseforge.Project, curseFile curseforge.File, old []*models.Version, ctx context.Context) ([]*models.Version, error) {
+	failure, err := getFileFailure(db, curseFile.Id)
+	if err != nil {
+		return nil, err
+	}
+	if failure != nil && !failure.CanRetry(time.Now()) {
+		return nil, ErrRetryLater
+	}
+
+	versions, err := reparseFile(db, project, curseFile, old, ctx)
+	if err != nil {
+		return nil, recordFileFailure(db, project, curseFile, failure, err, ctx)
+	}
+	metricReparsed.Add(1)
+
+	if failure != nil {
+		err = db.Delete(failure).Error
+	}
+	return versions, err
+}
+
+func reparseFile(db *gorm.DB, project curseforge.Project, curseFile curseforge.File, old []*models.Version, ctx context.Context) ([]*models.Version, error) {
+	//download before starting the transaction, so the database isn't held while waiting on CurseForge
+	modInfo, err := readFile(project, curseFile, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := versionIds(old)
+
+	var versions []*models.Version
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("version_id IN ?", ids).Delete(&models.VersionDependency{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("id IN ?", ids).Delete(&models.Version{}).Error
+		if err != nil {
+			return err
+		}
+		versions, err = saveVersions(tx, project, curseFile, modInfo, ctx)
+		return err
+	})
+	return versions, err
+}
+
+type outdatedFile struct {
+	CurseId uint
+	FileId  uint
+}
+
+// findOutdatedFiles lists files parsed by an older parser, starting with those where nothing was found, since those
+// are the most likely to have been missed. Files waiting on a retry are left out.
+func findOutdatedFiles(db *gorm.DB, limit int) ([]outdatedFile, error) {
+	waiting := db.Model(&models.FileFailure{}).Select("file_id").Where("permanent = ? OR next_retry > ?", true, time.Now())
+
+	var files []outdatedFile
+	err := db.Model(&models.Version{}).
+		Select("curse_id, file_id").
+		Where("parser_version < ?", parserVersion).
+		Where("file_id NOT IN (?)", waiting).
+		Group("curse_id, file_id").
+		Order("MIN(CASE WHEN mod_id = '' THEN 0 ELSE 1 END), file_id DESC").
+		Limit(limit).
+		Scan(&files).Error
+	return files, err
+}
+
+// startReparseJob parses outdated files again in the background, a batch at a time
+func startReparseJob(interval time.Duration, batch int) {
+	ctx := context.WithValue(context.Background(), logger.ContextKey, logger.New("Reparse"))
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			reparseOutdated(batch, ctx)
+		}
+	}()
+}
+
+func reparseOutdated(batch int, ctx context.Context) {
+	db, err := database.Get(ctx)
+	if err != nil {
+		logger.Printf(ctx, "Error: %s", err.Error())
+		return
+	}
+
+	files, err := findOutdatedFiles(db, batch)
+	if err != nil {
+		logger.Printf(ctx, "Failed to find outdated files: %s", err.Error())
+		return
+	}
+
+	projects := make(map[uint]curseforge.Project)
+	for _, v := range files {
+		project, exists := projects[v.CurseId]
+		if !exists {
+			project, err = curseforge.GetProject(v.CurseId, ctx)
+			if err != nil {
+				logger.Printf(ctx, "Failed to get project %d: %s", v.CurseId, err.Error())
+				continue
+			}
+			projects[v.CurseId] = project
+		}
+
+		var old []*models.Version
+		err = db.Where(&models.Version{CurseId: v.CurseId, FileId: v.FileId}).Find(&old).Error
+		if err != nil {
+			logger.Printf(ctx, "Error: %s", err.Error())
+			continue
+		}
+
+		curseFile, err := curseforge.GetFile(v.CurseId, v.FileId, ctx)
+		if errors.Is(err, curseforge.ErrInvalidFileId) {
+			//the file is gone from CurseForge, so there is nothing left to parse
+			err = db.Model(&models.Version{}).Where("id IN ?", versionIds(old)).Update("parser_version", parserVersion).Error
+		} else if err == nil {
+			_, err = tryReparse(db, project, curseFile, old, ctx)
+		}
+		if err != nil {
+			logger.Printf(ctx, "Failed to reparse file %d: %s", v.FileId, err.Error())
+		}
+	}
+}
+
+func versionIds(versions []*models.Version) []uint {
+	ids := make([]uint, 0, len(versions))
+	for _, v := range versions {
+		ids = append(ids, v.Id)
+	}
+	return ids
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Mode is what is kept of each file
+type Mode string
+
+const (
+	ModeFull     Mode = "full"
+	ModeMetadata Mode = "metadata"
+)
+
+var ErrNotFound = errors.New("file not in store")
+var ErrInvalidKey = errors.New("invalid store key")
+
+// keys are file hashes, so anything else is refused to keep paths inside the store
+var keyRegex = regexp.MustCompile("^[0-9a-f]{8,128}$")
+
+var dir string
+var maxSize int64
+var mode = ModeFull
+var lock sync.Mutex
+
+// Initialize sets where files are stored and how much space they may take. An empty directory disables the store.
+func Initialize(directory string, size int64, storeMode Mode) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	dir = directory
+	maxSize = size
+	mode = storeMode
+}
+
+// Enabled checks if a directory was given for the store
+func Enabled() bool {
+	return dir != ""
+}
+
+func GetMode() Mode {
+	return mode
+}
+
+// Get opens a stored file. Reading a file marks it as recently used, so it is evicted last.
+func Get(key string) (*os.File, int64, error) {
+	path, err := getPath(key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, ErrNotFound
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return f, info.Size(), nil
+}
+
+// Put stores the data under the key, then removes the least recently used files until the store fits its size
+func Put(key string, data io.Reader) error {
+	path, err := getPath(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	//write next to the final path, so a partial file is never read
+	f, err := os.CreateTemp(filepath.Dir(path), "*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, data)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return evict()
+}
+
+// Purge removes everything in the store
+func Purge() error {
+	if !Enabled() {
+		return nil
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, v := range entries {
+		err = os.RemoveAll(filepath.Join(dir, v.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns the number of files in the store and their total size
+func Stats() (int, int64, error) {
+	files, err := list()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var size int64
+	for _, v := range files {
+		size += v.size
+	}
+	return len(files), size, nil
+}
+
+func getPath(key string) (string, error) {
+	if !Enabled() {
+		return "", ErrNotFound
+	}
+	if !keyRegex.MatchString(key) {
+		return "", ErrInvalidKey
+	}
+	//split by the first characters so no single directory gets too large
+	return filepath.Join(dir, key[:2], key+".zip"), nil
+}
+
+type storedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func list() ([]storedFile, error) {
+	files := make([]storedFile, 0)
+	if !Enabled() {
+		return files, nil
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".zip" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, storedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil
+	}
+	return files, err
+}
+
+func evict() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	files, err := list()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, v := range files {
+		size += v.size
+	}
+
+	slices.SortFunc(files, func(a, b storedFile) int { return a.modTime.Compare(b.modTime) })
+	for _, v := range files {
+		if size <= maxSize {
+			break
+		}
+		if err = os.Remove(v.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		size -= v.size
+	}
+	return nil
+}
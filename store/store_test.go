@@ -0,0 +1,51 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Evict(t *testing.T) {
+	Initialize(t.TempDir(), 10, ModeFull)
+	t.Cleanup(func() { Initialize("", 0, ModeFull) })
+
+	assert.NoError(t, Put("aaaaaaaa", strings.NewReader("12345")))
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, Put("bbbbbbbb", strings.NewReader("12345")))
+	time.Sleep(10 * time.Millisecond)
+
+	//reading the first file makes the second the least recently used
+	f, size, err := Get("aaaaaaaa")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(5), size)
+		_ = f.Close()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	assert.NoError(t, Put("cccccccc", strings.NewReader("12345")))
+
+	_, _, err = Get("bbbbbbbb")
+	assert.ErrorIs(t, err, ErrNotFound)
+	for _, key := range []string{"aaaaaaaa", "cccccccc"} {
+		f, _, err = Get(key)
+		if assert.NoError(t, err, key) {
+			_ = f.Close()
+		}
+	}
+
+	count, total, err := Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, int64(10), total)
+
+	_, _, err = Get("../../etc/passwd")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	assert.NoError(t, Purge())
+	count, _, err = Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
@@ -24,6 +24,9 @@ func NewTempFile() (*TempFile, error) {
 }
 
 func (tf *TempFile) Close() error {
+	if tf == nil {
+		return nil
+	}
 	defer os.Remove(tf.file.Name())
 	return tf.file.Close()
 }
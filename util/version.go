@@ -0,0 +1,234 @@
+package util
+
+import (
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CompareVersions compares two versions the same way Maven's ComparableVersion does, which is what Forge uses for
+// both version ranges and its update checker. Returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	return parseVersion(a).compare(parseVersion(b))
+}
+
+var qualifiers = []string{"alpha", "beta", "milestone", "rc", "snapshot", "", "sp"}
+
+var qualifierAliases = map[string]string{
+	"ga":      "",
+	"final":   "",
+	"release": "",
+	"cr":      "rc",
+}
+
+var releaseQualifier = comparableQualifier("")
+
+type versionItem interface {
+	compare(other versionItem) int
+	isNull() bool
+}
+
+type intItem struct {
+	value *big.Int
+}
+
+type stringItem struct {
+	value string
+}
+
+type listItem struct {
+	items []versionItem
+}
+
+func (i intItem) isNull() bool {
+	return i.value.Sign() == 0
+}
+
+func (i intItem) compare(other versionItem) int {
+	switch o := other.(type) {
+	case nil:
+		if i.isNull() {
+			return 0
+		}
+		return 1
+	case intItem:
+		return i.value.Cmp(o.value)
+	default:
+		//1.1 > 1-sp and 1.1 > 1-1
+		return 1
+	}
+}
+
+func newStringItem(value string, followedByDigit bool) stringItem {
+	if followedByDigit && len(value) == 1 {
+		switch value {
+		case "a":
+			value = "alpha"
+		case "b":
+			value = "beta"
+		case "m":
+			value = "milestone"
+		}
+	}
+	if alias, exists := qualifierAliases[value]; exists {
+		value = alias
+	}
+	return stringItem{value: value}
+}
+
+func comparableQualifier(qualifier string) string {
+	i := slices.Index(qualifiers, qualifier)
+	if i == -1 {
+		return strconv.Itoa(len(qualifiers)) + "-" + qualifier
+	}
+	return strconv.Itoa(i)
+}
+
+func (s stringItem) isNull() bool {
+	return comparableQualifier(s.value) == releaseQualifier
+}
+
+func (s stringItem) compare(other versionItem) int {
+	switch o := other.(type) {
+	case nil:
+		return strings.Compare(comparableQualifier(s.value), releaseQualifier)
+	case stringItem:
+		return strings.Compare(comparableQualifier(s.value), comparableQualifier(o.value))
+	default:
+		//1.any < 1.1 and 1-any < 1-1
+		return -1
+	}
+}
+
+func (l *listItem) isNull() bool {
+	return len(l.items) == 0
+}
+
+func (l *listItem) compare(other versionItem) int {
+	switch o := other.(type) {
+	case nil:
+		if len(l.items) == 0 {
+			return 0
+		}
+		return l.items[0].compare(nil)
+	case intItem:
+		return -1
+	case stringItem:
+		return 1
+	case *listItem:
+		for i := 0; i < len(l.items) || i < len(o.items); i++ {
+			var left, right versionItem
+			if i < len(l.items) {
+				left = l.items[i]
+			}
+			if i < len(o.items) {
+				right = o.items[i]
+			}
+
+			var result int
+			if left == nil {
+				if right != nil {
+					result = -right.compare(nil)
+				}
+			} else {
+				result = left.compare(right)
+			}
+			if result != 0 {
+				return result
+			}
+		}
+	}
+	return 0
+}
+
+func (l *listItem) add(item versionItem) {
+	l.items = append(l.items, item)
+}
+
+// normalize drops trailing null items, so 1.0.0 and 1 are equal
+func (l *listItem) normalize() {
+	for i := len(l.items) - 1; i >= 0; i-- {
+		item := l.items[i]
+		if item.isNull() {
+			l.items = append(l.items[:i], l.items[i+1:]...)
+		} else if _, isList := item.(*listItem); !isList {
+			break
+		}
+	}
+}
+
+func parseItem(isDigit bool, value string) versionItem {
+	if isDigit {
+		v, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			v = new(big.Int)
+		}
+		return intItem{value: v}
+	}
+	return newStringItem(value, false)
+}
+
+func parseVersion(version string) *listItem {
+	version = strings.ToLower(version)
+
+	root := &listItem{}
+	list := root
+	stack := []*listItem{root}
+
+	isDigit := false
+	start := 0
+	runes := []rune(version)
+
+	for i, c := range runes {
+		switch {
+		case c == '.' || c == '-':
+			if i == start {
+				list.add(intItem{value: new(big.Int)})
+			} else {
+				list.add(parseItem(isDigit, string(runes[start:i])))
+			}
+			start = i + 1
+
+			if c == '-' {
+				next := &listItem{}
+				list.add(next)
+				list = next
+				stack = append(stack, list)
+			}
+		case unicode.IsDigit(c):
+			if !isDigit && i > start {
+				list.add(newStringItem(string(runes[start:i]), true))
+				start = i
+
+				next := &listItem{}
+				list.add(next)
+				list = next
+				stack = append(stack, list)
+			}
+			isDigit = true
+		default:
+			if isDigit && i > start {
+				list.add(parseItem(true, string(runes[start:i])))
+				start = i
+
+				next := &listItem{}
+				list.add(next)
+				list = next
+				stack = append(stack, list)
+			}
+			isDigit = false
+		}
+	}
+
+	if len(runes) > start {
+		list.add(parseItem(isDigit, string(runes[start:])))
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		stack[i].normalize()
+	}
+
+	return root
+}
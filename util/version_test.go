@@ -0,0 +1,71 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CompareVersions(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "1.0", b: "1", want: 0},
+		{a: "1.0.0", b: "1-ga", want: 0},
+		{a: "1.20.10", b: "1.20.2", want: 1},
+		{a: "1.0-alpha1", b: "1.0-beta1", want: -1},
+		{a: "1.0a1", b: "1.0-alpha-1", want: 0},
+		{a: "1.0-rc1", b: "1.0", want: -1},
+		{a: "1.0-SNAPSHOT", b: "1.0-rc1", want: 1},
+		{a: "1.0-sp", b: "1.0", want: 1},
+		{a: "1.0.1", b: "1.0-sp", want: 1},
+		{a: "5.8.0beta1", b: "5.7.3", want: 1},
+		{a: "5.8.0beta1", b: "5.8.0", want: -1},
+		{a: "1.0-foo", b: "1.0-sp", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, CompareVersions(tt.a, tt.b))
+			assert.Equal(t, -tt.want, CompareVersions(tt.b, tt.a))
+		})
+	}
+}
+
+func Test_VersionRange(t *testing.T) {
+	tests := []struct {
+		spec     string
+		loader   string
+		contains []string
+		excludes []string
+		bounded  bool
+	}{
+		{spec: "[1.20,1.20.2)", loader: "forge", contains: []string{"1.20", "1.20.1"}, excludes: []string{"1.19.4", "1.20.2"}, bounded: true},
+		{spec: "[1.19.2]", loader: "forge", contains: []string{"1.19.2"}, excludes: []string{"1.19.3"}, bounded: true},
+		{spec: "[1.18,1.19),[1.20,)", loader: "forge", contains: []string{"1.18.2", "1.21"}, excludes: []string{"1.19"}},
+		{spec: "1.20.1", loader: "forge", contains: []string{"1.12.2", "1.20.1"}},
+		{spec: ">=1.20 <1.20.2", loader: "fabric", contains: []string{"1.20", "1.20.1"}, excludes: []string{"1.20.2"}, bounded: true},
+		{spec: "1.20.x", loader: "fabric,quilt", contains: []string{"1.20", "1.20.6"}, excludes: []string{"1.21"}, bounded: true},
+		{spec: "~1.20.1", loader: "fabric", contains: []string{"1.20.4"}, excludes: []string{"1.20", "1.21"}, bounded: true},
+		{spec: "1.19.4 || 1.20", loader: "fabric", contains: []string{"1.19.4", "1.20"}, excludes: []string{"1.20.1"}, bounded: true},
+		{spec: "*", loader: "fabric", contains: []string{"1.7.10"}},
+		{spec: "[1.20,)", loader: "forge", contains: []string{"1.20", "99.0"}},
+		{spec: ">=1.20", loader: "fabric", contains: []string{"1.20", "99.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			r, err := ParseVersionRange(tt.spec, tt.loader)
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, v := range tt.contains {
+				assert.True(t, r.Contains(v), "expected %s to contain %s", tt.spec, v)
+			}
+			for _, v := range tt.excludes {
+				assert.False(t, r.Contains(v), "expected %s to not contain %s", tt.spec, v)
+			}
+			assert.Equal(t, tt.bounded, r.IsBounded())
+		})
+	}
+}
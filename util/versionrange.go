@@ -0,0 +1,239 @@
+package util
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidRange = errors.New("invalid version range")
+
+// VersionRange is a set of alternatives, where a version is contained if it matches every bound of any alternative
+type VersionRange struct {
+	Spec         string
+	alternatives [][]versionBound
+}
+
+type versionBound struct {
+	op      string
+	version string
+}
+
+func (r *VersionRange) Contains(version string) bool {
+	for _, alternative := range r.alternatives {
+		matches := true
+		for _, bound := range alternative {
+			if !bound.matches(version) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBounded checks if every alternative has both a lower and an upper bound, or is an exact version. Soft requirements,
+// wildcards and open ended ranges are not bounded.
+func (r *VersionRange) IsBounded() bool {
+	if len(r.alternatives) == 0 {
+		return false
+	}
+	for _, alternative := range r.alternatives {
+		lower, upper := false, false
+		for _, bound := range alternative {
+			switch bound.op {
+			case ">=", ">":
+				lower = true
+			case "<=", "<":
+				upper = true
+			default:
+				lower, upper = true, true
+			}
+		}
+		if !lower || !upper {
+			return false
+		}
+	}
+	return true
+}
+
+func (b versionBound) matches(version string) bool {
+	result := CompareVersions(version, b.version)
+	switch b.op {
+	case ">=":
+		return result >= 0
+	case ">":
+		return result > 0
+	case "<=":
+		return result <= 0
+	case "<":
+		return result < 0
+	default:
+		return result == 0
+	}
+}
+
+// ParseMavenRange reads a range in the format used by mods.toml, such as [1.20,1.20.2) or [1.19.2].
+// Several ranges may be separated by commas, and a plain version is a soft requirement that matches everything.
+func ParseMavenRange(spec string) (*VersionRange, error) {
+	result := &VersionRange{Spec: spec}
+	remaining := strings.TrimSpace(spec)
+
+	if remaining == "" || (!strings.HasPrefix(remaining, "[") && !strings.HasPrefix(remaining, "(")) {
+		result.alternatives = [][]versionBound{{}}
+		return result, nil
+	}
+
+	for remaining != "" {
+		if !strings.HasPrefix(remaining, "[") && !strings.HasPrefix(remaining, "(") {
+			return nil, ErrInvalidRange
+		}
+
+		end := strings.IndexAny(remaining, "])")
+		if end == -1 {
+			return nil, ErrInvalidRange
+		}
+
+		alternative, err := parseMavenRestriction(remaining[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		result.alternatives = append(result.alternatives, alternative)
+
+		remaining = strings.TrimSpace(remaining[end+1:])
+		remaining = strings.TrimSpace(strings.TrimPrefix(remaining, ","))
+	}
+
+	return result, nil
+}
+
+func parseMavenRestriction(spec string) ([]versionBound, error) {
+	lowerInclusive := spec[0] == '['
+	upperInclusive := spec[len(spec)-1] == ']'
+	inner := strings.TrimSpace(spec[1 : len(spec)-1])
+
+	lower, upper, hasComma := strings.Cut(inner, ",")
+	lower = strings.TrimSpace(lower)
+	upper = strings.TrimSpace(upper)
+
+	if !hasComma {
+		if !lowerInclusive || !upperInclusive || lower == "" {
+			return nil, ErrInvalidRange
+		}
+		return []versionBound{{op: "=", version: lower}}, nil
+	}
+
+	bounds := make([]versionBound, 0, 2)
+	if lower != "" {
+		op := ">"
+		if lowerInclusive {
+			op = ">="
+		}
+		bounds = append(bounds, versionBound{op: op, version: lower})
+	}
+	if upper != "" {
+		op := "<"
+		if upperInclusive {
+			op = "<="
+		}
+		bounds = append(bounds, versionBound{op: op, version: upper})
+	}
+	return bounds, nil
+}
+
+// ParseFabricRange reads the version predicates used by fabric.mod.json and quilt.mod.json. Predicates separated by
+// spaces must all match, while alternatives are separated by "||".
+func ParseFabricRange(spec string) (*VersionRange, error) {
+	result := &VersionRange{Spec: spec}
+
+	for alternative := range strings.SplitSeq(spec, "||") {
+		bounds := make([]versionBound, 0)
+		for predicate := range strings.FieldsSeq(alternative) {
+			parsed, err := parseFabricPredicate(predicate)
+			if err != nil {
+				return nil, err
+			}
+			bounds = append(bounds, parsed...)
+		}
+		result.alternatives = append(result.alternatives, bounds)
+	}
+
+	return result, nil
+}
+
+func parseFabricPredicate(predicate string) ([]versionBound, error) {
+	if predicate == "*" {
+		return []versionBound{}, nil
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if version, found := strings.CutPrefix(predicate, op); found {
+			if version == "" {
+				return nil, ErrInvalidRange
+			}
+			return []versionBound{{op: op, version: version}}, nil
+		}
+	}
+
+	//~1.20.1 allows patch changes, ^1.20.1 allows minor changes
+	if version, found := strings.CutPrefix(predicate, "~"); found {
+		return boundsForPrefix(version, 2)
+	}
+	if version, found := strings.CutPrefix(predicate, "^"); found {
+		return boundsForPrefix(version, 1)
+	}
+
+	//1.20.x matches anything starting with 1.20
+	if prefix, found := strings.CutSuffix(predicate, ".x"); found {
+		return boundsForPrefix(prefix, strings.Count(prefix, ".")+1)
+	}
+	if prefix, found := strings.CutSuffix(predicate, ".*"); found {
+		return boundsForPrefix(prefix, strings.Count(prefix, ".")+1)
+	}
+
+	return []versionBound{{op: "=", version: predicate}}, nil
+}
+
+// boundsForPrefix creates a range from the version up to (but excluding) the next increment of the component at the
+// given length, so a length of 2 for 1.20.1 is [1.20.1,1.21)
+func boundsForPrefix(version string, length int) ([]versionBound, error) {
+	parts := strings.Split(version, ".")
+	if version == "" || length < 1 {
+		return nil, ErrInvalidRange
+	}
+	for len(parts) < length {
+		parts = append(parts, "0")
+	}
+
+	upper := make([]string, length)
+	copy(upper, parts[:length])
+	last, err := strconv.Atoi(upper[length-1])
+	if err != nil {
+		return nil, ErrInvalidRange
+	}
+	upper[length-1] = strconv.Itoa(last + 1)
+
+	return []versionBound{
+		{op: ">=", version: version},
+		{op: "<", version: strings.Join(upper, ".")},
+	}, nil
+}
+
+// ParseVersionRange reads the range with the syntax used by the given loader. Maven brackets are always read as such,
+// since jars for several loaders may carry either format.
+func ParseVersionRange(spec string, loader string) (*VersionRange, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "[") || strings.HasPrefix(spec, "(") {
+		return ParseMavenRange(spec)
+	}
+
+	for v := range strings.SplitSeq(loader, ",") {
+		if v == "fabric" || v == "quilt" {
+			return ParseFabricRange(spec)
+		}
+	}
+	return ParseMavenRange(spec)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cfwidget/updatejson/cache"
+	"github.com/cfwidget/updatejson/logger"
+	"github.com/cfwidget/updatejson/models"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+	maxPage         = 10000
+)
+
+var ErrInvalidPage = errors.New("invalid page, page must be between 1 and 10000 and pageSize between 1 and 200")
+
+// versionFilter narrows down the versions listed. Empty fields match everything.
+type versionFilter struct {
+	GameVersion string
+	Loader      string
+	Channel     int8
+	Version     string
+	From        time.Time
+	To          time.Time
+	Page        int
+	PageSize    int
+}
+
+func getVersionFilter(c *gin.Context) (versionFilter, error) {
+	filter := versionFilter{
+		GameVersion: c.Query("mc"),
+		Loader:      getRequestedLoader(c),
+		Version:     c.Query("version"),
+		Page:        1,
+		PageSize:    defaultPageSize,
+	}
+
+	var err error
+	if filter.Channel, err = parseChannel(c.Query("channel")); err != nil {
+		return filter, err
+	}
+
+	if filter.From, err = parseOptionalTime(c.Query("from")); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseOptionalTime(c.Query("to")); err != nil {
+		return filter, err
+	}
+
+	if c.Query("page") != "" {
+		filter.Page, err = cast.ToIntE(c.Query("page"))
+		if err != nil || filter.Page < 1 || filter.Page > maxPage {
+			return filter, ErrInvalidPage
+		}
+	}
+	if c.Query("pageSize") != "" {
+		filter.PageSize, err = cast.ToIntE(c.Query("pageSize"))
+		if err != nil || filter.PageSize < 1 || filter.PageSize > maxPageSize {
+			return filter, ErrInvalidPage
+		}
+	}
+	return filter, nil
+}
+
+// parseOptionalTime reads an RFC3339 time, where nothing gives the zero time
+func parseOptionalTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return result, ErrInvalidTime
+	}
+	return result, nil
+}
+
+// matches checks if the version passes every filter
+func (f versionFilter) matches(version *models.Version) bool {
+	switch {
+	case f.Loader != "" && !supportsLoader(version.Loader, f.Loader):
+		return false
+	case !inChannel(version, f.Channel):
+		return false
+	case f.Version != "" && version.Version != f.Version:
+		return false
+	case !f.From.IsZero() && version.ReleaseDate.Before(f.From):
+		return false
+	case !f.To.IsZero() && !version.ReleaseDate.Before(f.To):
+		return false
+	case f.GameVersion != "" && !slices.Contains(strings.Split(version.GameVersions, ","), f.GameVersion):
+		return false
+	}
+	return true
+}
+
+// getVersionList returns every version of the mod we know of, newest first, a page at a time
+func getVersionList(c *gin.Context) {
+	pid := c.Param("projectId")
+	modId := c.Param("modId")
+
+	cacheKey := cache.GetKey(c)
+
+	var projectId uint
+	var err error
+	if projectId, err = cast.ToUintE(pid); err != nil {
+		cache.Set(cacheKey, http.StatusNotFound, nil)
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var data *models.VersionList
+	filter, err := getVersionFilter(c)
+	if err == nil {
+		data, err = getVersions(projectId, modId, filter, c.Request.Context())
+	}
+
+	if isBadRequest(err) {
+		d := map[string]string{"error": err.Error()}
+		cache.Set(cacheKey, http.StatusBadRequest, d)
+		c.JSON(http.StatusBadRequest, d)
+	} else if err != nil {
+		logger.Printf(c.Request.Context(), "Error: %s", err.Error())
+		d := map[string]string{"error": err.Error()}
+		cacheExpireTime := cache.Set(cacheKey, http.StatusInternalServerError, d)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.Status(http.StatusInternalServerError)
+	} else {
+		cacheExpireTime := cache.Set(cacheKey, http.StatusOK, *data)
+		cache.AddHeaders(c, cacheExpireTime)
+		c.JSON(http.StatusOK, data)
+	}
+}
+
+func getVersions(projectId uint, modId string, filter versionFilter, ctx context.Context) (*models.VersionList, error) {
+	_, versionMap, _, err := getProjectVersions(projectId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return listVersions(versionMap, modId, filter), nil
+}
+
+// listVersions picks the page of versions of the mod which pass the filter
+func listVersions(versionMap map[VersionKey]*models.Version, modId string, filter versionFilter) *models.VersionList {
+	versions := make([]*models.Version, 0)
+	for _, v := range versionMap {
+		if v.Version == "" || !matchesModId(v, modId) || !filter.matches(v) {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	slices.SortFunc(versions, func(a, b *models.Version) int {
+		if c := b.ReleaseDate.Compare(a.ReleaseDate); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.FileId, a.FileId)
+	})
+
+	result := &models.VersionList{
+		Versions: make([]models.VersionEntry, 0),
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Total:    len(versions),
+	}
+
+	start := min((filter.Page-1)*filter.PageSize, len(versions))
+	end := min(start+filter.PageSize, len(versions))
+	for _, v := range versions[start:end] {
+		gameVersions := make([]string, 0)
+		for _, gameVersion := range strings.Split(v.GameVersions, ",") {
+			if gameVersion != "" && !invalidGameVersionRegex.MatchString(gameVersion) {
+				gameVersions = append(gameVersions, gameVersion)
+			}
+		}
+
+		result.Versions = append(result.Versions, models.VersionEntry{
+			Version:      v.Version,
+			FileId:       v.FileId,
+			FileName:     v.FileName,
+			GameVersions: gameVersions,
+			Loaders:      strings.Split(v.Loader, ","),
+			ReleaseType:  getReleaseTypeName(v.Type),
+			ReleaseDate:  v.ReleaseDate,
+			Url:          v.Url,
+			DownloadUrl:  v.DownloadUrl,
+		})
+	}
+
+	return result
+}
@@ -8,25 +8,27 @@ import (
 	"sync"
 
 	"github.com/cfwidget/updatejson/curseforge"
-	"github.com/cfwidget/updatejson/env"
 	"github.com/cfwidget/updatejson/logger"
 	"github.com/cfwidget/updatejson/models"
 )
 
 var downloaderWorkerQueue chan *QueueItem
 var workers []*Worker
+var startWorkersOnce sync.Once
 
-func init() {
-	numWorkers := env.GetInt("DOWNLOADERS")
-	if numWorkers <= 0 {
-		numWorkers = runtime.NumCPU() / 2
-	}
-	downloaderWorkerQueue = make(chan *QueueItem, numWorkers*2)
-	for i := range numWorkers {
-		w := &Worker{Id: i, Logger: logger.New(fmt.Sprintf("Worker-%d", i)), Stop: make(chan bool)}
-		workers = append(workers, w)
-		go w.Start()
-	}
+// startWorkers starts the downloaders, using half of the CPUs when no number is given
+func startWorkers(numWorkers int) {
+	startWorkersOnce.Do(func() {
+		if numWorkers <= 0 {
+			numWorkers = max(runtime.NumCPU()/2, 1)
+		}
+		downloaderWorkerQueue = make(chan *QueueItem, numWorkers*2)
+		for i := range numWorkers {
+			w := &Worker{Id: i, Logger: logger.New(fmt.Sprintf("Worker-%d", i)), Stop: make(chan bool)}
+			workers = append(workers, w)
+			go w.Start()
+		}
+	})
 }
 
 func (w *Worker) Start() {
@@ -51,15 +53,18 @@ type Worker struct {
 func (w *Worker) ProcessItem(item *QueueItem) {
 	defer item.Wg.Done()
 	ctx := context.WithValue(item.Ctx, logger.ContextKey, w.Logger)
-	versionInfo, err := getModVersion(item.Project, item.File, item.ModId, ctx)
+	versions, err := getModVersions(item.Project, item.File, ctx)
+	item.Mutex.Lock()
+	defer item.Mutex.Unlock()
 	if err != nil {
 		w.Logger.Printf("Error getting mod version from file: %s", err.Error())
+		if item.Errors != nil {
+			item.Errors[item.File.Id] = err
+		}
 		return
 	}
-	item.Mutex.Lock()
-	defer item.Mutex.Unlock()
-	if versionInfo != nil {
-		item.VersionMap[item.File.Id] = versionInfo
+	for _, v := range versions {
+		item.VersionMap[VersionKey{FileId: v.FileId, ModId: v.ModId}] = v
 	}
 }
 
@@ -67,8 +72,23 @@ type QueueItem struct {
 	File       curseforge.File
 	Wg         *sync.WaitGroup
 	Mutex      *sync.Mutex
-	VersionMap map[uint]*models.Version
+	VersionMap map[VersionKey]*models.Version
+	Errors     map[uint]error
 	Ctx        context.Context
 	Project    curseforge.Project
-	ModId      string
+}
+
+// VersionKey identifies a single mod inside a file, since one jar may hold several mods
+type VersionKey struct {
+	FileId uint
+	ModId  string
+}
+
+// newVersionMap keys the versions by the file and mod they are for
+func newVersionMap(versions ...*models.Version) map[VersionKey]*models.Version {
+	versionMap := make(map[VersionKey]*models.Version, len(versions))
+	for _, v := range versions {
+		versionMap[VersionKey{FileId: v.FileId, ModId: v.ModId}] = v
+	}
+	return versionMap
 }